@@ -0,0 +1,406 @@
+// Package api реализует HTTP REST API pScan: управление списком
+// хостов, асинхронный запуск сканирования и получение его результатов.
+// Описание API в формате OpenAPI доступно по адресу /openapi.yaml
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/history"
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+const (
+	// maxScans - сколько завершённых сканирований хранится в памяти
+	maxScans = 100
+
+	// maxBodySize - наибольший размер тела запроса в байтах
+	maxBodySize = 1 << 20
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// Состояния сканирования
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// scanJob - асинхронно выполняемое сканирование
+type scanJob struct {
+	ID         string         `json:"id"`
+	Status     string         `json:"status"`
+	Ports      []int          `json:"ports"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	RunID      string         `json:"run_id,omitempty"`
+	results    []scan.Results `json:"-"`
+}
+
+// Server - HTTP API pScan
+type Server struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	hostsFile string
+	ports     []int
+	opts      scan.Options
+	store     *history.Store
+
+	// OnScan вызывается после каждого успешного сканирования.
+	// Если хранилище не задано, идентификатор запуска пустой
+	OnScan func(run history.Run)
+
+	// hostsMu защищает файл хостов от одновременного изменения
+	hostsMu sync.Mutex
+
+	mu    sync.Mutex
+	scans map[string]*scanJob
+	order []string
+	// active - идентификатор выполняемого сканирования. Одновременно
+	// выполняется не больше одного сканирования
+	active string
+	wg     sync.WaitGroup
+}
+
+// New возвращает сервер API для списка хостов из файла hostsFile.
+// ports - порты, сканируемые, если в запросе они не указаны.
+// Сканирования выполняются в контексте ctx и прерываются его отменой
+// или вызовом Close, а их результаты сохраняются в store, если он не nil
+func New(ctx context.Context, hostsFile string, ports []int, opts scan.Options, store *history.Store) *Server {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Server{
+		ctx:       ctx,
+		cancel:    cancel,
+		hostsFile: hostsFile,
+		ports:     ports,
+		opts:      opts,
+		store:     store,
+		scans:     make(map[string]*scanJob),
+	}
+}
+
+// Handler возвращает обработчик HTTP запросов API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /openapi.yaml", s.openAPI)
+	mux.HandleFunc("GET /hosts", s.listHosts)
+	mux.HandleFunc("POST /hosts", s.addHosts)
+	mux.HandleFunc("DELETE /hosts/{host...}", s.deleteHost)
+	mux.HandleFunc("POST /scans", s.startScan)
+	mux.HandleFunc("GET /scans/{id}", s.scanStatus)
+	mux.HandleFunc("GET /scans/{id}/results", s.scanResults)
+	return mux
+}
+
+// Wait дожидается завершения запущенных сканирований
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+// Close прерывает запущенные сканирования и дожидается их завершения
+func (s *Server) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// errorResponse - тело ответа с ошибкой
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeJSON записывает v в ответ с кодом code
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError записывает ошибку err в ответ с кодом code
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Error: err.Error()})
+}
+
+// readJSON декодирует JSON тело запроса r в v. Пустое тело оставляет
+// v без изменений. Если тело не удалось прочитать, ошибка записывается
+// в ответ w, а readJSON возвращает false
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v)
+
+	var maxErr *http.MaxBytesError
+	switch {
+	case err == nil, errors.Is(err, io.EOF):
+		return true
+	case errors.As(err, &maxErr):
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+	default:
+		writeError(w, http.StatusBadRequest, err)
+	}
+	return false
+}
+
+func (s *Server) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
+
+// hostsBody - тело запроса и ответа со списком хостов
+type hostsBody struct {
+	Hosts []string `json:"hosts"`
+}
+
+func (s *Server) listHosts(w http.ResponseWriter, r *http.Request) {
+	s.hostsMu.Lock()
+	defer s.hostsMu.Unlock()
+
+	hl := &scan.HostsList{}
+	if err := hl.Load(s.hostsFile); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body := hostsBody{Hosts: hl.Hosts}
+	if body.Hosts == nil {
+		body.Hosts = []string{}
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) addHosts(w http.ResponseWriter, r *http.Request) {
+	var body hostsBody
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if len(body.Hosts) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("не указано ни одного хоста"))
+		return
+	}
+
+	s.hostsMu.Lock()
+	defer s.hostsMu.Unlock()
+
+	hl := &scan.HostsList{}
+	if err := hl.Load(s.hostsFile); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Список сохраняется, только если добавлены все хосты
+	for _, h := range body.Hosts {
+		if err := hl.Add(h); err != nil {
+			code := http.StatusBadRequest
+			if errors.Is(err, scan.ErrExists) {
+				code = http.StatusConflict
+			}
+			writeError(w, code, err)
+			return
+		}
+	}
+
+	if err := hl.Save(s.hostsFile); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, hostsBody{Hosts: hl.Hosts})
+}
+
+func (s *Server) deleteHost(w http.ResponseWriter, r *http.Request) {
+	s.hostsMu.Lock()
+	defer s.hostsMu.Unlock()
+
+	hl := &scan.HostsList{}
+	if err := hl.Load(s.hostsFile); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := hl.Remove(r.PathValue("host")); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := hl.Save(s.hostsFile); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scanRequest - тело запроса на запуск сканирования
+type scanRequest struct {
+	// Ports - спецификация портов в синтаксисе --ports
+	Ports string `json:"ports"`
+}
+
+func (s *Server) startScan(w http.ResponseWriter, r *http.Request) {
+	var req scanRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	ports := s.ports
+	if req.Ports != "" {
+		var err error
+		if ports, err = scan.ParsePorts(req.Ports); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	s.hostsMu.Lock()
+	hl := &scan.HostsList{}
+	err := hl.Load(s.hostsFile)
+	s.hostsMu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	job := &scanJob{
+		ID:        newID(),
+		Status:    StatusRunning,
+		Ports:     ports,
+		StartedAt: time.Now(),
+	}
+	if id, ok := s.addScan(job); !ok {
+		w.Header().Set("Location", "/scans/"+id)
+		writeError(w, http.StatusConflict, errScanBusy)
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.runScan(job, hl)
+	}()
+
+	w.Header().Set("Location", "/scans/"+job.ID)
+	writeJSON(w, http.StatusAccepted, s.snapshot(job))
+}
+
+// runScan выполняет сканирование job и сохраняет его результаты
+func (s *Server) runScan(job *scanJob, hl *scan.HostsList) {
+	results, err := scan.RunContext(s.ctx, hl, job.Ports, s.opts)
+
+	run := history.Run{
+		Time:      job.StartedAt,
+		HostsFile: s.hostsFile,
+		Ports:     job.Ports,
+		Results:   results,
+	}
+	if err == nil && s.store != nil {
+		run, err = s.store.Add(run)
+	}
+	if err == nil && s.OnScan != nil {
+		s.OnScan(run)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.active = ""
+	now := time.Now()
+	job.FinishedAt = &now
+	job.results = results
+	job.RunID = run.ID
+	if err != nil {
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		return
+	}
+	job.Status = StatusDone
+}
+
+// addScan регистрирует сканирование, удаляя самые старые
+// завершённые, если их больше maxScans. Если уже выполняется
+// другое сканирование, job не регистрируется, а addScan возвращает
+// идентификатор выполняемого и false
+func (s *Server) addScan(job *scanJob) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active != "" {
+		return s.active, false
+	}
+	s.active = job.ID
+	s.scans[job.ID] = job
+	s.order = append(s.order, job.ID)
+
+	for i := 0; len(s.order) > maxScans && i < len(s.order); {
+		id := s.order[i]
+		if s.scans[id].Status == StatusRunning {
+			i++
+			continue
+		}
+		delete(s.scans, id)
+		s.order = append(s.order[:i], s.order[i+1:]...)
+	}
+	return job.ID, true
+}
+
+// snapshot возвращает копию состояния сканирования
+func (s *Server) snapshot(job *scanJob) scanJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *job
+}
+
+// lookup возвращает копию состояния сканирования по идентификатору
+func (s *Server) lookup(id string) (scanJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.scans[id]
+	if !ok {
+		return scanJob{}, false
+	}
+	return *job, true
+}
+
+var (
+	errScanNotFound = errors.New("сканирование не найдено")
+	errScanRunning  = errors.New("сканирование ещё выполняется")
+	errScanBusy     = errors.New("уже выполняется другое сканирование")
+)
+
+func (s *Server) scanStatus(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.lookup(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errScanNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) scanResults(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.lookup(r.PathValue("id"))
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound, errScanNotFound)
+		return
+	case job.Status == StatusRunning:
+		writeError(w, http.StatusConflict, errScanRunning)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	scan.WriteJSON(w, job.results)
+}
+
+// newID возвращает случайный идентификатор сканирования
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,346 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"vegorov.ru/go-cli/pScan/api"
+	"vegorov.ru/go-cli/pScan/history"
+	"vegorov.ru/go-cli/pScan/scan"
+	"vegorov.ru/go-cli/pScan/scan/scantest"
+)
+
+// setup запускает тестовый сервер API с файлом хостов hosts
+func setup(t *testing.T, hosts ...string) (*httptest.Server, string, *history.Store) {
+	t.Helper()
+
+	dir := t.TempDir()
+	hostsFile := filepath.Join(dir, "pScan.hosts")
+
+	hl := &scan.HostsList{}
+	for _, h := range hosts {
+		if err := hl.Add(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hl.Save(hostsFile); err != nil {
+		t.Fatal(err)
+	}
+
+	store := history.NewStore(filepath.Join(dir, "history"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := api.New(ctx, hostsFile, []int{22}, scan.Options{Timeout: 500 * time.Millisecond}, store)
+	ts := httptest.NewServer(srv.Handler())
+
+	t.Cleanup(func() {
+		ts.Close()
+		cancel()
+		srv.Wait()
+	})
+
+	return ts, hostsFile, store
+}
+
+// do выполняет запрос и декодирует JSON ответ в v
+func do(t *testing.T, method, url, body string, v any) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("Ошибка декодирования ответа: %q\n", err)
+		}
+	}
+	return resp
+}
+
+type hostsBody struct {
+	Hosts []string `json:"hosts"`
+}
+
+func TestHosts(t *testing.T) {
+	ts, hostsFile, _ := setup(t, "host1")
+
+	var list hostsBody
+	resp := do(t, http.MethodGet, ts.URL+"/hosts", "", &list)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Ожидали код %d, получили %d\n", http.StatusOK, resp.StatusCode)
+	}
+	if strings.Join(list.Hosts, ",") != "host1" {
+		t.Errorf("Ожидали хосты %q, получили %q\n", "host1", list.Hosts)
+	}
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		expCode    int
+		expHostsIn string
+	}{
+		{"Add", http.MethodPost, "/hosts", `{"hosts":["host2","10.0.0.0/30"]}`,
+			http.StatusCreated, "host1\nhost2\n10.0.0.0/30\n"},
+		{"AddExisting", http.MethodPost, "/hosts", `{"hosts":["host3","host1"]}`,
+			http.StatusConflict, "host1\nhost2\n10.0.0.0/30\n"},
+		{"AddInvalid", http.MethodPost, "/hosts", `{"hosts":["10.0.0.0/40"]}`,
+			http.StatusBadRequest, "host1\nhost2\n10.0.0.0/30\n"},
+		{"AddNewline", http.MethodPost, "/hosts", `{"hosts":["host3\nhost4"]}`,
+			http.StatusBadRequest, "host1\nhost2\n10.0.0.0/30\n"},
+		{"AddSpace", http.MethodPost, "/hosts", `{"hosts":["host3 host4"]}`,
+			http.StatusBadRequest, "host1\nhost2\n10.0.0.0/30\n"},
+		{"AddBlank", http.MethodPost, "/hosts", `{"hosts":[""]}`,
+			http.StatusBadRequest, "host1\nhost2\n10.0.0.0/30\n"},
+		{"AddEmpty", http.MethodPost, "/hosts", `{}`,
+			http.StatusBadRequest, "host1\nhost2\n10.0.0.0/30\n"},
+		{"AddBadJSON", http.MethodPost, "/hosts", `hosts`,
+			http.StatusBadRequest, "host1\nhost2\n10.0.0.0/30\n"},
+		{"DeleteCIDR", http.MethodDelete, "/hosts/10.0.0.0/30", "",
+			http.StatusNoContent, "host1\nhost2\n"},
+		{"Delete", http.MethodDelete, "/hosts/host1", "",
+			http.StatusNoContent, "host2\n"},
+		{"DeleteMissing", http.MethodDelete, "/hosts/host1", "",
+			http.StatusNotFound, "host2\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := do(t, tc.method, ts.URL+tc.path, tc.body, nil)
+			if resp.StatusCode != tc.expCode {
+				t.Errorf("Ожидали код %d, получили %d\n", tc.expCode, resp.StatusCode)
+			}
+
+			data, err := os.ReadFile(hostsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tc.expHostsIn {
+				t.Errorf("Ожидали файл хостов %q, получили %q\n", tc.expHostsIn, string(data))
+			}
+		})
+	}
+}
+
+type scanStatus struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Ports  []int  `json:"ports"`
+	Error  string `json:"error"`
+	RunID  string `json:"run_id"`
+}
+
+func TestScan(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	ts, _, store := setup(t, "localhost")
+
+	var started scanStatus
+	resp := do(t, http.MethodPost, ts.URL+"/scans",
+		`{"ports":"`+strconv.Itoa(port)+`"}`, &started)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Ожидали код %d, получили %d\n", http.StatusAccepted, resp.StatusCode)
+	}
+	if loc := resp.Header.Get("Location"); loc != "/scans/"+started.ID {
+		t.Errorf("Ожидали Location %q, получили %q\n", "/scans/"+started.ID, loc)
+	}
+
+	// Ожидание завершения сканирования
+	var status scanStatus
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		do(t, http.MethodGet, ts.URL+"/scans/"+started.ID, "", &status)
+		if status.Status != api.StatusRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Сканирование не завершилось")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if status.Status != api.StatusDone {
+		t.Fatalf("Ожидали состояние %q, получили %q (%s)\n", api.StatusDone, status.Status, status.Error)
+	}
+	if len(status.Ports) != 1 || status.Ports[0] != port {
+		t.Errorf("Ожидали порты %v, получили %v\n", []int{port}, status.Ports)
+	}
+
+	resp, err = http.Get(ts.URL + "/scans/" + started.ID + "/results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	results, err := scan.ReadJSON(resp.Body)
+	if err != nil {
+		t.Fatalf("Ошибка чтения результатов: %q\n", err)
+	}
+	if len(results) != 1 || len(results[0].PortStates) != 1 {
+		t.Fatalf("Неожиданные результаты: %v\n", results)
+	}
+	if results[0].PortStates[0].Status != scan.StatusOpen {
+		t.Errorf("Ожидали состояние %q, получили %q\n", scan.StatusOpen, results[0].PortStates[0].Status)
+	}
+
+	run, err := store.Get(status.RunID)
+	if err != nil {
+		t.Fatalf("Запуск %q не сохранён в историю: %q\n", status.RunID, err)
+	}
+	if len(run.Results) != 1 {
+		t.Errorf("Ожидали 1 результат в истории, получили %d\n", len(run.Results))
+	}
+}
+
+func TestScanBusy(t *testing.T) {
+	dir := t.TempDir()
+	hostsFile := filepath.Join(dir, "pScan.hosts")
+	hl := &scan.HostsList{}
+	hl.Add("192.0.2.1")
+	if err := hl.Save(hostsFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// Соединения не завершаются, пока не отменён ctx
+	d := scantest.NewDialer()
+	d.Default = scantest.Outcome{Delay: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := api.New(ctx, hostsFile, []int{22}, scan.Options{Dialer: d, Timeout: time.Hour}, nil)
+	ts := httptest.NewServer(srv.Handler())
+	defer func() {
+		ts.Close()
+		cancel()
+		srv.Wait()
+	}()
+
+	var started scanStatus
+	resp := do(t, http.MethodPost, ts.URL+"/scans", "", &started)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Ожидали код %d, получили %d\n", http.StatusAccepted, resp.StatusCode)
+	}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	resp = do(t, http.MethodPost, ts.URL+"/scans", `{"ports":"80"}`, &body)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Ожидали код %d, получили %d\n", http.StatusConflict, resp.StatusCode)
+	}
+	if body.Error == "" {
+		t.Error("Ожидали описание ошибки в ответе")
+	}
+	if loc := resp.Header.Get("Location"); loc != "/scans/"+started.ID {
+		t.Errorf("Ожидали Location %q, получили %q\n", "/scans/"+started.ID, loc)
+	}
+}
+
+func TestScanChunkedEmptyBody(t *testing.T) {
+	ts, _, _ := setup(t, "localhost")
+
+	// Пустое тело, переданное с Transfer-Encoding: chunked, имеет
+	// неизвестную длину
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/scans", io.NopCloser(strings.NewReader("")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.ContentLength = -1
+	req.TransferEncoding = []string{"chunked"}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Ожидали код %d, получили %d\n", http.StatusAccepted, resp.StatusCode)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	ts, _, _ := setup(t, "localhost")
+
+	testCases := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		expCode int
+	}{
+		{"InvalidPorts", http.MethodPost, "/scans", `{"ports":"70000"}`, http.StatusBadRequest},
+		{"BadJSON", http.MethodPost, "/scans", `ports`, http.StatusBadRequest},
+		{"TooLarge", http.MethodPost, "/scans", `{"ports":"` + strings.Repeat("1,", 1<<20) + `1"}`,
+			http.StatusRequestEntityTooLarge},
+		{"HostsTooLarge", http.MethodPost, "/hosts", `{"hosts":["` + strings.Repeat("h", 1<<20) + `"]}`,
+			http.StatusRequestEntityTooLarge},
+		{"UnknownScan", http.MethodGet, "/scans/unknown", "", http.StatusNotFound},
+		{"UnknownResults", http.MethodGet, "/scans/unknown/results", "", http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body struct {
+				Error string `json:"error"`
+			}
+			resp := do(t, tc.method, ts.URL+tc.path, tc.body, &body)
+			if resp.StatusCode != tc.expCode {
+				t.Errorf("Ожидали код %d, получили %d\n", tc.expCode, resp.StatusCode)
+			}
+			if body.Error == "" {
+				t.Error("Ожидали описание ошибки в ответе")
+			}
+		})
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	ts, _, _ := setup(t)
+
+	resp, err := http.Get(ts.URL + "/openapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Ожидали код %d, получили %d\n", http.StatusOK, resp.StatusCode)
+	}
+
+	var spec struct {
+		OpenAPI string         `yaml:"openapi"`
+		Paths   map[string]any `yaml:"paths"`
+	}
+	if err := yaml.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		t.Fatalf("Ошибка разбора описания API: %q\n", err)
+	}
+	for _, p := range []string{"/hosts", "/hosts/{host}", "/scans", "/scans/{id}", "/scans/{id}/results"} {
+		if _, ok := spec.Paths[p]; !ok {
+			t.Errorf("Путь %s отсутствует в описании API\n", p)
+		}
+	}
+}
@@ -2,15 +2,29 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
-
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"vegorov.ru/go-cli/pScan/api"
+	"vegorov.ru/go-cli/pScan/history"
+	"vegorov.ru/go-cli/pScan/metrics"
+	"vegorov.ru/go-cli/pScan/notify"
+	"vegorov.ru/go-cli/pScan/policy"
 	"vegorov.ru/go-cli/pScan/scan"
+	"vegorov.ru/go-cli/pScan/schedule"
 )
 
 func setup(t *testing.T, hosts []string, initList bool) (string, func()) {
@@ -145,7 +159,7 @@ func TestIntegration(t *testing.T) {
 	}
 
 	// Сканируем хосты
-	if err := scanAction(&out, tf, nil); err != nil {
+	if err := scanAction(context.Background(), &out, io.Discard, scanConfig{hostsFile: tf, output: outputOptions{format: "text"}}); err != nil {
 		t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
 	}
 
@@ -197,7 +211,7 @@ func TestScanAction(t *testing.T) {
 
 	var out bytes.Buffer
 
-	if err := scanAction(&out, tf, ports); err != nil {
+	if err := scanAction(context.Background(), &out, io.Discard, scanConfig{hostsFile: tf, ports: ports, output: outputOptions{format: "text"}}); err != nil {
 		t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
 	}
 
@@ -205,3 +219,636 @@ func TestScanAction(t *testing.T) {
 		t.Errorf("Ожидали получить:\n%q, а получили:\n%q\n", expectedOut, out.String())
 	}
 }
+
+func TestScanActionInterrupted(t *testing.T) {
+	tf, cleanup := setup(t, []string{"localhost"}, true)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var out, errOut bytes.Buffer
+	err := scanAction(ctx, &out, &errOut, scanConfig{hostsFile: tf, ports: []int{22}, output: outputOptions{format: "text"}})
+
+	var ee *exitError
+	if !errors.Is(err, errInterrupted) || !errors.As(err, &ee) || ee.code != interruptedCode {
+		t.Fatalf("Ожидали ошибку %q с кодом %d, а получили %v\n", errInterrupted, interruptedCode, err)
+	}
+
+	if !strings.Contains(errOut.String(), "Сканирование прервано") {
+		t.Errorf("Ожидали сообщение о прерывании, а получили %q\n", errOut.String())
+	}
+}
+
+// resetFlags восстанавливает после теста значения флагов команд cmds,
+// которые тест меняет разбором аргументов
+func resetFlags(t *testing.T, cmds ...*cobra.Command) {
+	t.Helper()
+	for _, c := range cmds {
+		// Постоянные флаги попадают в Flags() только при разборе
+		c.Flags().AddFlagSet(c.PersistentFlags())
+		c.Flags().VisitAll(func(f *pflag.Flag) {
+			if v, ok := f.Value.(pflag.SliceValue); ok {
+				saved := slices.Clone(v.GetSlice())
+				t.Cleanup(func() {
+					v.Replace(saved)
+					f.Changed = false
+				})
+				return
+			}
+			saved := f.Value.String()
+			t.Cleanup(func() {
+				f.Value.Set(saved)
+				f.Changed = false
+			})
+		})
+	}
+}
+
+func TestPortsFlagRepeated(t *testing.T) {
+	resetFlags(t, scanCmd)
+	f := scanCmd.Flags().Lookup("ports")
+
+	c := &cobra.Command{}
+	c.Flags().AddFlag(f)
+	if err := c.ParseFlags([]string{"-p", "22", "-p", "80,!443", "--ports", "8000-8001"}); err != nil {
+		t.Fatal(err)
+	}
+
+	specs, err := c.Flags().GetStringArray("ports")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ports, err := scan.ParsePorts(strings.Join(specs, ","))
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+	}
+
+	expected := []int{22, 80, 8000, 8001}
+	if !slices.Equal(ports, expected) {
+		t.Errorf("Ожидали порты %v, а получили %v\n", expected, ports)
+	}
+}
+
+func TestSelectPorts(t *testing.T) {
+	viper.Set("profiles.test", "22,5432")
+	viper.Set("profiles.list", []any{8080, "https"})
+	viper.Set("profiles.noweb", "!http,!https")
+	viper.Set("profiles.bad", map[string]any{"web": 80})
+	defer func() {
+		for _, name := range []string{"test", "list", "noweb", "bad"} {
+			viper.Set("profiles."+name, nil)
+		}
+	}()
+
+	testCases := []struct {
+		name        string
+		spec        string
+		top         int
+		profiles    []string
+		expected    []int
+		expectedErr error
+	}{
+		{"PortsOnly", "22,80", 0, nil, []int{22, 80}, nil},
+		{"Top", "", 3, nil, []int{80, 23, 443}, nil},
+		{"Profile", "", 0, []string{"test"}, []int{22, 5432}, nil},
+		{"BuiltinProfile", "", 0, []string{"mail"}, []int{25, 587, 465, 110, 995, 143, 993}, nil},
+		{"Combined", "8080", 2, []string{"test"}, []int{8080, 80, 23, 22, 5432}, nil},
+		{"UnknownProfile", "", 0, []string{"no-such"}, nil, errUnknownProfile},
+		{"Nothing", "", 0, nil, nil, scan.ErrEmptyPorts},
+		{"ExcludeFromTop", "!23", 3, nil, []int{80, 443}, nil},
+		{"ExcludeFromProfile", "!5432", 0, []string{"test"}, []int{22}, nil},
+		{"ProfileExcludes", "", 3, []string{"noweb"}, []int{23}, nil},
+		{"OnlyExclusions", "!22", 0, nil, nil, scan.ErrEmptyPorts},
+		{"ListProfile", "", 0, []string{"list"}, []int{8080, 443}, nil},
+		{"InvalidProfile", "", 0, []string{"bad"}, nil, errInvalidProfile},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ports, err := selectPorts(tc.spec, tc.top, tc.profiles)
+
+			if tc.expectedErr != nil {
+				if !errors.Is(err, tc.expectedErr) {
+					t.Fatalf("Ожидали ошибку %q, а получили %v\n", tc.expectedErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+			}
+
+			if !slices.Equal(ports, tc.expected) {
+				t.Errorf("Ожидали порты %v, а получили %v\n", tc.expected, ports)
+			}
+		})
+	}
+}
+
+func TestHistoryActions(t *testing.T) {
+	tf, cleanup := setup(t, []string{"not-found-host"}, true)
+	defer cleanup()
+
+	store := history.NewStore(t.TempDir())
+
+	// Два запуска сканирования с сохранением в историю
+	for i := 0; i < 2; i++ {
+		var out bytes.Buffer
+		if err := scanAction(context.Background(), &out, io.Discard, scanConfig{hostsFile: tf, ports: []int{22}, output: outputOptions{format: "text"}, store: store}); err != nil {
+			t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+		}
+	}
+
+	runs, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("Ожидали 2 запуска в истории, а получили %d\n", len(runs))
+	}
+
+	var out bytes.Buffer
+	if err := historyListAction(&out, store); err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], runs[0].ID+"\t") ||
+		!strings.HasSuffix(lines[0], "\t"+tf+"\tхостов: 1, открытых портов: 0") {
+		t.Errorf("Неверный список запусков:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := historyShowAction(&out, store, runs[1].ID, outputOptions{format: "text"}); err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+	}
+
+	if !strings.HasPrefix(out.String(), "Запуск: "+runs[1].ID+"\n") ||
+		!strings.HasSuffix(out.String(), "\nnot-found-host\nХост не найден\n") {
+		t.Errorf("Неверный вывод запуска:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := historyPruneAction(&out, store, 0, 1); err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+	}
+
+	if out.String() != "Удалено запусков: 1\n" {
+		t.Errorf("Ожидали вывод %q, а получили %q\n", "Удалено запусков: 1\n", out.String())
+	}
+
+	if err := historyPruneAction(&out, store, 0, 0); !errors.Is(err, errPruneCondition) {
+		t.Errorf("Ожидали ошибку %q, а получили %v\n", errPruneCondition, err)
+	}
+}
+
+func TestDiffAction(t *testing.T) {
+	store := history.NewStore(t.TempDir())
+
+	before := []scan.Results{
+		{Host: "host1", PortStates: []scan.PortState{{Port: 22, Status: scan.StatusOpen}}},
+	}
+	after := []scan.Results{
+		{Host: "host1", PortStates: []scan.PortState{
+			{Port: 22, Status: scan.StatusOpen},
+			{Port: 25, Status: scan.StatusOpen},
+		}},
+	}
+
+	runA, err := store.Add(history.Run{Results: before})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Второе сканирование берём из JSON файла
+	f, err := os.CreateTemp(t.TempDir(), "after*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := scan.WriteJSON(f, after); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// writeResults сохраняет результаты в JSON файл и возвращает его
+	writeResults := func(results []scan.Results) *os.File {
+		f, err := os.CreateTemp(t.TempDir(), "results*.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := scan.WriteJSON(f, results); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	// Порт 53 открыт по UDP, а TCP порт 53 не сканировался
+	udp := writeResults([]scan.Results{{Host: "host1", PortStates: []scan.PortState{
+		{Port: 22, Status: scan.StatusOpen},
+		{Port: 53, Protocol: "udp", Status: scan.StatusOpen},
+	}}})
+
+	// Ошибка проверки открытого порта - не дрейф
+	failed := writeResults([]scan.Results{{Host: "host1", PortStates: []scan.PortState{
+		{Port: 22, Status: scan.StatusError, Reason: "too many open files"},
+	}}})
+
+	testCases := []struct {
+		name         string
+		a, b         string
+		format       string
+		expectedOut  string
+		expectedCode int
+	}{
+		{"NoDrift", runA.ID, runA.ID, "text", "Изменений нет\n", 0},
+		{"Drift", runA.ID, f.Name(), "text", "Открыт порт: host1 25/tcp (было: closed)\n", 1},
+		{"UDPDrift", runA.ID, udp.Name(), "text", "Открыт порт: host1 53/udp (было: closed)\n", 1},
+		{"PortError", runA.ID, failed.Name(), "text",
+			"Изменений нет\nНе удалось проверить порт: host1 22/tcp (был открыт)\n", 0},
+		{"NotFound", runA.ID, "no-such-run", "text", "", 2},
+		{"BadFormat", runA.ID, runA.ID, "xml", "", 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := diffAction(&out, store, tc.a, tc.b, tc.format)
+
+			code := 0
+			if err != nil {
+				var ee *exitError
+				if !errors.As(err, &ee) {
+					t.Fatalf("Ожидали ошибку с кодом завершения, а получили: %q\n", err)
+				}
+				code = ee.code
+			}
+
+			if code != tc.expectedCode {
+				t.Errorf("Ожидали код завершения %d, а получили %d (%v)\n", tc.expectedCode, code, err)
+			}
+
+			if out.String() != tc.expectedOut {
+				t.Errorf("Ожидали вывод %q, а получили %q\n", tc.expectedOut, out.String())
+			}
+		})
+	}
+}
+
+func TestScanPolicy(t *testing.T) {
+	ln, err := net.Listen("tcp", net.JoinHostPort("localhost", "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name         string
+		hosts        []string
+		rules        string
+		expectedErr  string
+		expectedCode int
+	}{
+		{"Compliant", []string{"localhost"}, "open: " + port, "", 0},
+		{"Violation", []string{"localhost"}, "closed: " + port, "Нарушение политики: localhost: порт " + port + " должен быть closed, а он open (группа test)\n", 1},
+		{"ScanError", []string{"localhost", "not-found-host"}, "open: " + port, "Ошибка сканирования: not-found-host: хост не найден\n", 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tf, cleanup := setup(t, tc.hosts, true)
+			defer cleanup()
+
+			p, err := policy.Parse([]byte("groups: [{name: test, hosts: ['*'], " + tc.rules + "}]"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out, errOut bytes.Buffer
+			cfg := scanConfig{
+				hostsFile: tf,
+				output:    outputOptions{format: "text"},
+				policy:    p,
+			}
+			err = scanAction(context.Background(), &out, &errOut, cfg)
+
+			code := 0
+			if err != nil {
+				var ee *exitError
+				if !errors.As(err, &ee) {
+					t.Fatalf("Ожидали ошибку с кодом завершения, а получили: %q\n", err)
+				}
+				code = ee.code
+			}
+
+			if code != tc.expectedCode {
+				t.Errorf("Ожидали код завершения %d, а получили %d (%v)\n", tc.expectedCode, code, err)
+			}
+
+			// Порт из политики сканируется, даже если не задан явно
+			if !strings.Contains(out.String(), "\t"+port+": open\n") {
+				t.Errorf("Ожидали в выводе состояние порта %s:\n%s", port, out.String())
+			}
+
+			if errOut.String() != tc.expectedErr {
+				t.Errorf("Ожидали вывод ошибок %q, а получили %q\n", tc.expectedErr, errOut.String())
+			}
+		})
+	}
+}
+
+func TestScanCommandPolicyExit(t *testing.T) {
+	tf, cleanup := setup(t, []string{"localhost"}, true)
+	defer cleanup()
+
+	pf, err := os.CreateTemp("", "pScanPolicy*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(pf.Name())
+	pf.WriteString("groups: [{name: test, hosts: ['*'], open: '1'}]\n")
+	pf.Close()
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs([]string{"scan", "--hosts-file", tf, "-p", "1", "--policy", pf.Name(), "-o", "json"})
+	resetFlags(t, rootCmd, scanCmd)
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+	})
+
+	err = rootCmd.Execute()
+
+	var ee *exitError
+	if !errors.As(err, &ee) || ee.code != 1 {
+		t.Fatalf("Ожидали код завершения 1, а получили %v\n", err)
+	}
+
+	// CI видит только сообщение об ошибке, без справки по флагам
+	if strings.Contains(out.String(), "Usage:") {
+		t.Errorf("Не ожидали справку по использованию, а получили:\n%s\n", out.String())
+	}
+	if !strings.Contains(out.String(), errPolicyViolations.Error()) {
+		t.Errorf("Ожидали сообщение %q, а получили:\n%s\n", errPolicyViolations, out.String())
+	}
+}
+
+func TestLoadJobs(t *testing.T) {
+	defer viper.Set("jobs", nil)
+
+	viper.Set("jobs", []map[string]any{
+		{"name": "dmz", "hosts-file": "dmz.hosts", "ports": "ssh,8000-8001", "interval": "15m"},
+		{"name": "default", "interval": "1h"},
+	})
+
+	jobs, err := loadJobs()
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+	}
+
+	if len(jobs) != 2 {
+		t.Fatalf("Ожидали 2 задания, а получили %d\n", len(jobs))
+	}
+
+	if jobs[0].Name != "dmz" || jobs[0].HostsFile != "dmz.hosts" || jobs[0].Interval != 15*time.Minute ||
+		!slices.Equal(jobs[0].Ports, []int{22, 8000, 8001}) {
+		t.Errorf("Неверное задание: %+v\n", jobs[0])
+	}
+
+	// Значения по умолчанию команды scan
+	if jobs[1].HostsFile != viper.GetString("hosts-file") || !slices.Equal(jobs[1].Ports, []int{22, 80, 443}) {
+		t.Errorf("Неверное задание: %+v\n", jobs[1])
+	}
+
+	viper.Set("jobs", nil)
+	if _, err := loadJobs(); !errors.Is(err, errNoJobs) {
+		t.Errorf("Ожидали ошибку %q, а получили %v\n", errNoJobs, err)
+	}
+}
+
+func TestServeActionAPI(t *testing.T) {
+	hostsFile, cleanup := setup(t, []string{"host1"}, true)
+	defer cleanup()
+
+	s, err := schedule.New(nil, nil, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := api.New(ctx, hostsFile, []int{22}, scan.Options{}, nil)
+
+	m := metrics.NewCollector()
+	m.Observe("dmz", []scan.Results{{Host: "host1", NotFound: true}}, time.Second)
+
+	done := make(chan error, 1)
+	var out bytes.Buffer
+	go func() {
+		done <- serveAction(ctx, &out, s, ln, srv, m)
+	}()
+
+	testCases := []struct {
+		path    string
+		expBody string
+	}{
+		{"/hosts", "{\"hosts\":[\"host1\"]}\n"},
+		{"/metrics", "# HELP pscan_host_resolvable"},
+	}
+
+	for _, tc := range testCases {
+		resp, err := http.Get("http://" + ln.Addr().String() + tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(string(body), tc.expBody) {
+			t.Errorf("%s: ожидали ответ %q, а получили %q\n", tc.path, tc.expBody, string(body))
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Не ожидали ошибку, а получили: %q\n", err)
+	}
+
+	expOut := fmt.Sprintf("HTTP API: http://%s\n", ln.Addr())
+	if out.String() != expOut {
+		t.Errorf("Ожидали вывод %q, а получили %q\n", expOut, out.String())
+	}
+}
+
+func TestServeActionServeError(t *testing.T) {
+	hostsFile, cleanup := setup(t, []string{"host1"}, true)
+	defer cleanup()
+
+	s, err := schedule.New([]schedule.Job{
+		{Name: "dmz", HostsFile: hostsFile, Ports: []int{22}, Interval: time.Hour},
+	}, nil, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Закрытый слушатель - hs.Serve сразу завершается ошибкой
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+
+	srv := api.New(context.Background(), hostsFile, []int{22}, scan.Options{}, nil)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- serveAction(context.Background(), io.Discard, s, ln, srv, metrics.NewCollector())
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Ожидали ошибку %q, а получили %q\n", net.ErrClosed, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveAction не завершилась после ошибки HTTP сервера")
+	}
+}
+
+func TestScanOptionsSeed(t *testing.T) {
+	defer viper.Set("seed", nil)
+
+	testCases := []struct {
+		name          string
+		seed          any
+		expSeed       uint64
+		expRandomSeed bool
+	}{
+		{"NoSeed", nil, 0, true},
+		{"Seed", 42, 42, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			viper.Set("seed", tc.seed)
+
+			opts, err := scanOptions()
+			if err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+			}
+			if opts.Seed != tc.expSeed || opts.RandomSeed != tc.expRandomSeed {
+				t.Errorf("Ожидали Seed %d и RandomSeed %t, а получили %d и %t\n",
+					tc.expSeed, tc.expRandomSeed, opts.Seed, opts.RandomSeed)
+			}
+		})
+	}
+}
+
+func TestLoadNotifier(t *testing.T) {
+	defer viper.Set("notifiers", nil)
+
+	testCases := []struct {
+		name      string
+		notifiers []map[string]any
+		expErr    error
+	}{
+		{"Empty", nil, nil},
+		{"AllTypes", []map[string]any{
+			{"type": "webhook", "url": "http://localhost/hook", "retries": 2, "backoff": "1s"},
+			{"type": "command", "command": "true", "events": []string{"port-opened"}},
+			{"type": "maildrop", "path": "mbox"},
+		}, nil},
+		{"UnknownType", []map[string]any{{"type": "sms"}}, errUnknownNotifier},
+		{"UnknownEvent", []map[string]any{{"type": "command", "events": []string{"port-changed"}}}, notify.ErrUnknownEvent},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			viper.Set("notifiers", tc.notifiers)
+
+			_, err := loadNotifier(io.Discard)
+			if tc.expErr == nil && err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+			}
+			if tc.expErr != nil && !errors.Is(err, tc.expErr) {
+				t.Errorf("Ожидали ошибку %q, а получили %v\n", tc.expErr, err)
+			}
+		})
+	}
+}
+
+func TestSeedNotifier(t *testing.T) {
+	store := history.NewStore(t.TempDir())
+
+	for _, r := range []history.Run{
+		{Job: "dmz", Results: []scan.Results{{Host: "host1", PortStates: []scan.PortState{{Port: 22, Status: scan.StatusOpen}}}}},
+		{Results: []scan.Results{{Host: "host1"}}},
+	} {
+		if _, err := store.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n := notify.New(io.Discard)
+	if err := seedNotifier(n, store); err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+	}
+
+	events := n.Handle("dmz", []scan.Results{{Host: "host1", PortStates: []scan.PortState{{Port: 22}}}})
+	if len(events) != 1 || events[0].Type != notify.PortClosed {
+		t.Errorf("Ожидали событие закрытия порта, а получили %v\n", events)
+	}
+}
+
+func TestPrintResultsDetails(t *testing.T) {
+	results := []scan.Results{{
+		Host: "localhost",
+		PortStates: []scan.PortState{
+			{Port: 25, Open: true, Status: scan.StatusOpen, Banner: "220-mail.example.com\n220 ESMTP"},
+			{Port: 80, Open: true, Status: scan.StatusOpen},
+			{Port: 443, Open: true, Status: scan.StatusOpen, Service: "https", Product: "nginx", Version: "1.25.3",
+				TLS: &scan.TLSInfo{
+					Version:     "TLS 1.3",
+					CipherSuite: "TLS_AES_128_GCM_SHA256",
+					Subject:     "CN=example.com",
+					SANs:        []string{"example.com", "www.example.com"},
+					Issuer:      "CN=R11,O=Let's Encrypt,C=US",
+					NotAfter:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+					ExpiresSoon: true,
+				}},
+		},
+	}}
+
+	var out bytes.Buffer
+	if err := printResults(&out, results); err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+	}
+
+	expected := "localhost\n\t25: open\n\t\t220-mail.example.com\n\t\t220 ESMTP\n\t80: open\n" +
+		"\t443: open https nginx 1.25.3\n" +
+		"\t\tTLS 1.3 TLS_AES_128_GCM_SHA256\n" +
+		"\t\tСубъект: CN=example.com\n" +
+		"\t\tИмена: example.com, www.example.com\n" +
+		"\t\tИздатель: CN=R11,O=Let's Encrypt,C=US\n" +
+		"\t\tДействителен до: 2026-01-02 (expires-soon)\n"
+	if out.String() != expected {
+		t.Errorf("Ожидали вывод %q, а получили %q\n", expected, out.String())
+	}
+}
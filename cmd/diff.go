@@ -0,0 +1,170 @@
+/*
+Copyright © 2025 Vladimir Egorov
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"vegorov.ru/go-cli/pScan/history"
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+var errDrift = errors.New("обнаружены изменения")
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <runA> <runB>",
+	Short: "Сравнить результаты двух сканирований",
+	Long: `Сравнивает результаты двух сканирований и выводит изменения:
+открывшиеся и закрывшиеся порты, сменившиеся сервисы, появившиеся
+и исчезнувшие хосты, хосты, имена которых стали или перестали разрешаться.
+
+Каждый аргумент - идентификатор запуска из истории (pScan history list)
+или файл с результатами в формате json или ndjson.
+
+Коды завершения: 0 - изменений нет, 1 - есть изменения, 2 - ошибка.`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return &exitError{2, err}
+		}
+
+		store, err := historyStore()
+		if err != nil {
+			return &exitError{2, err}
+		}
+
+		return diffAction(os.Stdout, store, args[0], args[1], format)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringP("output", "o", "text", "Формат вывода изменений: text, json")
+}
+
+// loadResults загружает результаты сканирования из файла source,
+// а если такого файла нет - из запуска в истории с идентификатором source
+func loadResults(store *history.Store, source string) ([]scan.Results, error) {
+	f, err := os.Open(source)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		run, err := store.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		return run.Results, nil
+	}
+	defer f.Close()
+
+	return scan.ReadJSON(f)
+}
+
+// diffAction сравнивает сканирования a и b. Если изменения есть,
+// возвращает ошибку с кодом завершения 1, а при прочих ошибках - с кодом 2
+func diffAction(out io.Writer, store *history.Store, a, b, format string) error {
+	if format != "text" && format != "json" {
+		return &exitError{2, fmt.Errorf("%w: %q", errUnknownFormat, format)}
+	}
+
+	before, err := loadResults(store, a)
+	if err != nil {
+		return &exitError{2, err}
+	}
+
+	after, err := loadResults(store, b)
+	if err != nil {
+		return &exitError{2, err}
+	}
+
+	d := scan.Compare(before, after)
+
+	if format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(d)
+	} else {
+		err = printDiff(out, d)
+	}
+	if err != nil {
+		return &exitError{2, err}
+	}
+
+	if !d.Empty() {
+		return &exitError{1, errDrift}
+	}
+	return nil
+}
+
+// portName возвращает порт изменения c вместе с протоколом,
+// например "53/udp"
+func portName(c scan.PortChange) string {
+	protocol := c.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	return fmt.Sprintf("%d/%s", c.Port, protocol)
+}
+
+func printDiff(out io.Writer, d scan.Diff) error {
+	message := ""
+	if d.Empty() {
+		message = "Изменений нет\n"
+	}
+	for _, h := range d.Appeared {
+		message += fmt.Sprintf("Хост появился: %s\n", h)
+	}
+	for _, h := range d.Disappeared {
+		message += fmt.Sprintf("Хост исчез: %s\n", h)
+	}
+	for _, h := range d.Resolvable {
+		message += fmt.Sprintf("Хост стал разрешаться: %s\n", h)
+	}
+	for _, h := range d.Unresolvable {
+		message += fmt.Sprintf("Хост перестал разрешаться: %s\n", h)
+	}
+	for _, c := range d.Opened {
+		message += fmt.Sprintf("Открыт порт: %s %s (было: %s)\n", c.Host, portName(c), c.From)
+	}
+	for _, c := range d.Closed {
+		message += fmt.Sprintf("Закрыт порт: %s %s (стало: %s)\n", c.Host, portName(c), c.To)
+	}
+	for _, c := range d.ServiceChanged {
+		message += fmt.Sprintf("Сменился сервис: %s %s (%s -> %s)\n", c.Host, portName(c), c.OldService, c.NewService)
+	}
+	for _, c := range d.Failed {
+		message += fmt.Sprintf("Не удалось проверить порт: %s %s (был открыт)\n", c.Host, portName(c))
+	}
+
+	_, err := fmt.Fprint(out, message)
+	return err
+}
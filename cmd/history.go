@@ -0,0 +1,59 @@
+/*
+Copyright © 2025 Vladimir Egorov
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"vegorov.ru/go-cli/pScan/history"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Manage the scan results history",
+	Long: `Manages the history of pScan runs
+
+Scans are recorded with pScan scan --save-history
+or with save-history: true in the config file.
+
+List past runs with the list command
+Show one run with the show command
+Remove old runs with the prune command.`,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
+
+// historyStore возвращает хранилище истории из каталога,
+// заданного параметром history-dir, или из каталога по умолчанию
+func historyStore() (*history.Store, error) {
+	dir := viper.GetString("history-dir")
+	if dir == "" {
+		var err error
+		if dir, err = history.DefaultDir(); err != nil {
+			return nil, err
+		}
+	}
+	return history.NewStore(dir), nil
+}
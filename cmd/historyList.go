@@ -0,0 +1,75 @@
+/*
+Copyright © 2025 Vladimir Egorov
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"vegorov.ru/go-cli/pScan/history"
+)
+
+// historyListCmd represents the history list command
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Вывести список прошлых запусков сканирования",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+		return historyListAction(os.Stdout, store)
+	},
+}
+
+func init() {
+	historyCmd.AddCommand(historyListCmd)
+}
+
+func historyListAction(out io.Writer, store *history.Store) error {
+	runs, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		hosts, open := 0, 0
+		for _, r := range run.Results {
+			hosts++
+			for _, p := range r.PortStates {
+				if p.Open {
+					open++
+				}
+			}
+		}
+
+		_, err := fmt.Fprintf(out, "%s\t%s\t%s\tхостов: %d, открытых портов: %d\n",
+			run.ID, run.Time.Local().Format(time.DateTime), run.HostsFile, hosts, open)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
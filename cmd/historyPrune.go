@@ -0,0 +1,80 @@
+/*
+Copyright © 2025 Vladimir Egorov
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"vegorov.ru/go-cli/pScan/history"
+)
+
+var errPruneCondition = errors.New("укажите --older-than и/или --keep")
+
+// historyPruneCmd represents the history prune command
+var historyPruneCmd = &cobra.Command{
+	Use:          "prune",
+	Short:        "Удалить старые запуски из истории",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+
+		olderThan, err := cmd.Flags().GetDuration("older-than")
+		if err != nil {
+			return err
+		}
+
+		keep, err := cmd.Flags().GetInt("keep")
+		if err != nil {
+			return err
+		}
+
+		return historyPruneAction(os.Stdout, store, olderThan, keep)
+	},
+}
+
+func init() {
+	historyCmd.AddCommand(historyPruneCmd)
+	historyPruneCmd.Flags().Duration("older-than", 0, "Удалить запуски старше указанного времени, например 720h")
+	historyPruneCmd.Flags().Int("keep", 0, "Оставить только N последних запусков")
+}
+
+func historyPruneAction(out io.Writer, store *history.Store, olderThan time.Duration, keep int) error {
+	if olderThan <= 0 && keep <= 0 {
+		return errPruneCondition
+	}
+
+	removed, err := store.Prune(olderThan, keep)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, "Удалено запусков:", removed)
+	return err
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © 2025 Vladimir Egorov
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"vegorov.ru/go-cli/pScan/history"
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+// historyShowCmd represents the history show command
+var historyShowCmd = &cobra.Command{
+	Use:          "show <run-id>",
+	Short:        "Вывести результаты прошлого запуска сканирования",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+
+		format, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+
+		columns, err := cmd.Flags().GetStringSlice("columns")
+		if err != nil {
+			return err
+		}
+
+		output := outputOptions{
+			format:  format,
+			columns: columns,
+		}
+
+		return historyShowAction(os.Stdout, store, args[0], output)
+	},
+}
+
+func init() {
+	historyCmd.AddCommand(historyShowCmd)
+	historyShowCmd.Flags().StringP("output", "o", "text", `Формат вывода результатов: text, json, ndjson,
+xml (nmap -oX), grepable (nmap -oG), csv, tsv`)
+	historyShowCmd.Flags().StringSlice("columns", scan.CSVColumns, "Колонки для форматов csv и tsv")
+}
+
+func historyShowAction(out io.Writer, store *history.Store, id string, output outputOptions) error {
+	write, err := resultsWriter(output)
+	if err != nil {
+		return err
+	}
+
+	run, err := store.Get(id)
+	if err != nil {
+		return err
+	}
+
+	// Заголовок выводим только в текстовом формате,
+	// чтобы не нарушать машиночитаемые форматы
+	if output.format == "" || output.format == "text" {
+		_, err := fmt.Fprintf(out, "Запуск: %s\nВремя: %s\nФайл хостов: %s\nПорты: %v\n\n",
+			run.ID, run.Time.Local().Format(time.DateTime), run.HostsFile, run.Ports)
+		if err != nil {
+			return err
+		}
+	}
+
+	return write(out, run.Results)
+}
@@ -22,6 +22,7 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -54,7 +55,29 @@ target ports using a command line flag.`,
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	cobra.CheckErr(rootCmd.Execute())
+	err := rootCmd.Execute()
+
+	var ee *exitError
+	if errors.As(err, &ee) {
+		os.Exit(ee.code)
+	}
+	cobra.CheckErr(err)
+}
+
+// exitError - ошибка команды, завершающая программу с кодом code.
+// Используется командами, код завершения которых что-то означает
+// для вызывающей стороны, например в сценариях CI
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
 }
 
 func init() {
@@ -73,7 +96,11 @@ func init() {
 	viper.SetEnvKeyReplacer(replacer)
 	viper.SetEnvPrefix("PSCAN")
 
+	// Каталог истории запусков сканирования
+	rootCmd.PersistentFlags().String("history-dir", "", "Каталог истории запусков (по умолчанию каталог данных пользователя)")
+
 	viper.BindPFlag("hosts-file", rootCmd.PersistentFlags().Lookup("hosts-file"))
+	viper.BindPFlag("history-dir", rootCmd.PersistentFlags().Lookup("history-dir"))
 
 	versionTemplate := `{{printf "%s: %s - version %s\n" .Name .Short .Version}}`
 	rootCmd.SetVersionTemplate(versionTemplate)
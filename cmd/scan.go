@@ -22,57 +22,474 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"vegorov.ru/go-cli/pScan/history"
+	"vegorov.ru/go-cli/pScan/metrics"
+	"vegorov.ru/go-cli/pScan/policy"
 	"vegorov.ru/go-cli/pScan/scan"
 )
 
+var (
+	errUnknownProfile = errors.New("неизвестный профиль портов")
+	errInvalidProfile = errors.New("профиль портов должен быть строкой или списком портов")
+	errUnknownFormat  = errors.New("неизвестный формат вывода")
+
+	errPolicyViolations = errors.New("обнаружены нарушения политики")
+	errScanErrors       = errors.New("сканирование завершилось с ошибками")
+
+	errInterrupted = errors.New("сканирование прервано")
+)
+
+// defaultPorts - порты, сканируемые по умолчанию
+const defaultPorts = "22,80,443"
+
+// interruptedCode - код завершения прерванного сканирования,
+// как у процесса, завершённого по SIGINT
+const interruptedCode = 130
+
 // scanCmd represents the scan command
 var scanCmd = &cobra.Command{
-	Use:   "scan",
-	Short: "Выполнить сканирование открытых портов хостов",
+	Use:          "scan",
+	Short:        "Выполнить сканирование открытых портов хостов",
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		hostsFile := viper.GetString("hosts-file")
 
-		ports, err := cmd.Flags().GetIntSlice("ports")
+		// Повторные -p дополняют друг друга, как отдельные элементы
+		// одной спецификации
+		portsSpecs, err := cmd.Flags().GetStringArray("ports")
+		if err != nil {
+			return err
+		}
+		portsSpec := strings.Join(portsSpecs, ",")
+
+		top, err := cmd.Flags().GetInt("top")
+		if err != nil {
+			return err
+		}
+
+		profiles, err := cmd.Flags().GetStringSlice("profile")
+		if err != nil {
+			return err
+		}
+
+		// Порты по умолчанию не добавляем, если пользователь выбрал
+		// порты только через --top или --profile
+		if !cmd.Flags().Changed("ports") && (top > 0 || len(profiles) > 0) {
+			portsSpec = ""
+		}
+
+		ports, err := selectPorts(portsSpec, top, profiles)
+		if err != nil {
+			return err
+		}
+
+		workers, err := cmd.Flags().GetInt("workers")
+		if err != nil {
+			return err
+		}
+
+		opts, err := scanOptions()
+		if err != nil {
+			return err
+		}
+		opts.Workers = workers
+
+		// Без заданного seed порядок выбирается случайно, а seed
+		// выводится, чтобы запуск можно было повторить
+		if opts.Randomize && opts.RandomSeed {
+			opts.Seed, opts.RandomSeed = rand.Uint64(), false
+			fmt.Fprintf(os.Stderr, "Случайный порядок проверки: --seed %d\n", opts.Seed)
+		}
+
+		format, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+
+		columns, err := cmd.Flags().GetStringSlice("columns")
 		if err != nil {
 			return err
 		}
 
-		return scanAction(os.Stdout, hostsFile, ports)
+		output := outputOptions{
+			format:  format,
+			columns: columns,
+		}
+
+		cfg := scanConfig{
+			hostsFile: hostsFile,
+			ports:     ports,
+			opts:      opts,
+			output:    output,
+		}
+
+		if viper.GetBool("save-history") {
+			if cfg.store, err = historyStore(); err != nil {
+				return err
+			}
+		}
+
+		// В режиме проверки политики любая ошибка завершает
+		// программу с кодом 2
+		if policyFile := viper.GetString("policy"); policyFile != "" {
+			if cfg.policy, err = policy.Load(policyFile); err != nil {
+				return &exitError{2, err}
+			}
+		}
+
+		// По Ctrl-C прерываем сканирование и выводим то, что успели собрать
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		err = scanAction(ctx, os.Stdout, os.Stderr, cfg)
+
+		// О прерывании scanAction уже сообщила в stderr
+		if errors.Is(err, errInterrupted) {
+			cmd.SilenceErrors = true
+		}
+
+		var ee *exitError
+		if err != nil && cfg.policy != nil && !errors.As(err, &ee) {
+			return &exitError{2, err}
+		}
+		return err
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(scanCmd)
-	scanCmd.Flags().IntSliceP("ports", "p", []int{22, 80, 443}, "Порты для сканирования")
+	scanCmd.Flags().StringArrayP("ports", "p", []string{defaultPorts}, `Порты для сканирования: номера, диапазоны, имена сервисов
+и исключения, например "1-1024,8080,https,!25". Флаг можно повторять`)
+	scanCmd.Flags().Int("top", 0, "Добавить N наиболее распространённых TCP портов")
+	scanCmd.Flags().StringSlice("profile", nil, `Добавить порты именованных профилей из файла конфигурации,
+например "web,db"`)
+	scanCmd.Flags().IntP("workers", "w", scan.DefaultWorkers, "Количество параллельных проверок портов")
+	scanCmd.Flags().Duration("timeout", scan.DefaultTimeout, "Время ожидания соединения с портом")
+	scanCmd.Flags().Int("retries", 0, "Количество повторных попыток соединения с портом")
+	scanCmd.Flags().Duration("backoff", 100*time.Millisecond, "Пауза между попытками соединения")
+
+	scanCmd.Flags().StringP("output", "o", "text", `Формат вывода результатов: text, json, ndjson,
+xml (nmap -oX), grepable (nmap -oG), csv, tsv,
+prometheus (textfile коллектор node_exporter)`)
+	scanCmd.Flags().StringSlice("columns", scan.CSVColumns, "Колонки для форматов csv и tsv")
+	scanCmd.Flags().Bool("save-history", false, "Сохранить результаты в историю запусков")
+	scanCmd.Flags().String("policy", "", `YAML файл политики с ожидаемым состоянием портов.
+Коды завершения: 0 - соответствует, 1 - есть нарушения, 2 - ошибки сканирования`)
+	scanCmd.Flags().Int("max-hosts", scan.DefaultMaxHosts, "Наибольшее количество адресов при раскрытии подсетей и диапазонов")
+	scanCmd.Flags().Bool("banner", false, "Читать приветствие сервиса на открытых портах")
+	scanCmd.Flags().Duration("banner-timeout", scan.DefaultBannerTimeout, "Время ожидания приветствия сервиса")
+	scanCmd.Flags().Bool("service-detect", false, "Определять сервис и его версию на открытых портах пробами")
+	scanCmd.Flags().Duration("probe-timeout", scan.DefaultProbeTimeout, "Время ожидания ответа на пробу сервиса")
+	scanCmd.Flags().Float64("rate", 0, "Наибольшее количество новых соединений в секунду, 0 - без ограничения")
+	scanCmd.Flags().Int("host-concurrency", 0, "Наибольшее количество одновременных проверок одного хоста, 0 - без ограничения")
+	scanCmd.Flags().Duration("jitter", 0, "Наибольшая случайная пауза перед каждым соединением")
+	scanCmd.Flags().Bool("udp", false, `Сканировать UDP порты вместо TCP. Известным сервисам
+отправляются запросы их протоколов, остальным - пустая датаграмма`)
+	scanCmd.Flags().String("ip-version", "", `Семейство адресов: 4, 6 или both. Каждый адрес хоста
+сканируется отдельно. По умолчанию адрес выбирает резолвер`)
+	scanCmd.Flags().Bool("randomize", false, "Проверять пары хост/порт в случайном порядке")
+	scanCmd.Flags().Uint64("seed", 0, "Начальное значение генератора для --randomize, повторяет порядок проверки")
+	scanCmd.Flags().Bool("tls", false, "Получать параметры TLS и сертификат сервера на открытых портах")
+	scanCmd.Flags().Duration("expiry-warning", scan.DefaultExpiryWarning, "За сколько до истечения срока сертификат считается истекающим")
+
+	viper.BindPFlag("timeout", scanCmd.Flags().Lookup("timeout"))
+	viper.BindPFlag("retries", scanCmd.Flags().Lookup("retries"))
+	viper.BindPFlag("backoff", scanCmd.Flags().Lookup("backoff"))
+	viper.BindPFlag("max-hosts", scanCmd.Flags().Lookup("max-hosts"))
+	viper.BindPFlag("save-history", scanCmd.Flags().Lookup("save-history"))
+	viper.BindPFlag("policy", scanCmd.Flags().Lookup("policy"))
+	viper.BindPFlag("banner", scanCmd.Flags().Lookup("banner"))
+	viper.BindPFlag("banner-timeout", scanCmd.Flags().Lookup("banner-timeout"))
+	viper.BindPFlag("service-detect", scanCmd.Flags().Lookup("service-detect"))
+	viper.BindPFlag("probe-timeout", scanCmd.Flags().Lookup("probe-timeout"))
+	viper.BindPFlag("rate", scanCmd.Flags().Lookup("rate"))
+	viper.BindPFlag("host-concurrency", scanCmd.Flags().Lookup("host-concurrency"))
+	viper.BindPFlag("jitter", scanCmd.Flags().Lookup("jitter"))
+	viper.BindPFlag("udp", scanCmd.Flags().Lookup("udp"))
+	viper.BindPFlag("ip-version", scanCmd.Flags().Lookup("ip-version"))
+	viper.BindPFlag("randomize", scanCmd.Flags().Lookup("randomize"))
+	viper.BindPFlag("seed", scanCmd.Flags().Lookup("seed"))
+	viper.BindPFlag("tls", scanCmd.Flags().Lookup("tls"))
+	viper.BindPFlag("expiry-warning", scanCmd.Flags().Lookup("expiry-warning"))
+
+	// Встроенные профили портов. Их можно переопределить
+	// или дополнить в разделе profiles файла конфигурации
+	viper.SetDefault("profiles.web", "http,https,http-alt,https-alt,8000,8008,8888")
+	viper.SetDefault("profiles.db", "mysql,postgresql,ms-sql-s,oracle,mongodb,redis,memcache,elasticsearch,couchdb")
+	viper.SetDefault("profiles.mail", "smtp,submission,submissions,pop3,pop3s,imap,imaps")
+	viper.SetDefault("profiles.windows", "msrpc,netbios-ssn,microsoft-ds,kerberos,ldap,ldaps,rdp,winrm,winrm-https")
+	viper.SetDefault("profiles.k8s", "kube-apiserver,kubelet,etcd-client,etcd-server,10255-10257,10259")
 }
 
-func scanAction(out io.Writer, hostsFile string, ports []int) error {
+// selectPorts собирает итоговый список портов из спецификации
+// портов, N популярных портов и именованных профилей. Исключения
+// ("!25") из спецификации и профилей применяются ко всем источникам
+func selectPorts(spec string, top int, profiles []string) ([]int, error) {
+	var lists, excluded [][]int
+
+	if spec != "" {
+		include, exclude, err := scan.ParsePortSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, include)
+		excluded = append(excluded, exclude)
+	}
+
+	if top > 0 {
+		ports, err := scan.TopPorts(top)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, ports)
+	}
+
+	for _, name := range profiles {
+		profileSpec, err := profilePorts(name)
+		if err != nil {
+			return nil, err
+		}
+		include, exclude, err := scan.ParsePortSpec(profileSpec)
+		if err != nil {
+			return nil, fmt.Errorf("профиль %s: %w", name, err)
+		}
+		lists = append(lists, include)
+		excluded = append(excluded, exclude)
+	}
+
+	ports := scan.ExcludePorts(scan.MergePorts(lists...), scan.MergePorts(excluded...))
+	if len(ports) == 0 {
+		return nil, scan.ErrEmptyPorts
+	}
+	return ports, nil
+}
+
+// profilePorts возвращает спецификацию портов профиля name из
+// конфигурации. Профиль задаётся строкой ("80,443,http-alt")
+// или списком YAML ([80, 443, http-alt])
+func profilePorts(name string) (string, error) {
+	key := "profiles." + name
+	if !viper.IsSet(key) {
+		return "", fmt.Errorf("%w: %s", errUnknownProfile, name)
+	}
+
+	switch v := viper.Get(key).(type) {
+	case string:
+		return v, nil
+	case int:
+		return strconv.Itoa(v), nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item := item.(type) {
+			case string:
+				items = append(items, item)
+			case int:
+				items = append(items, strconv.Itoa(item))
+			default:
+				return "", fmt.Errorf("%w: %s: %v", errInvalidProfile, name, item)
+			}
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("%w: %s", errInvalidProfile, name)
+	}
+}
+
+// outputOptions - параметры вывода результатов сканирования
+type outputOptions struct {
+	format  string
+	columns []string
+}
+
+// scanConfig - параметры команды scan
+type scanConfig struct {
+	hostsFile string
+	ports     []int
+	opts      scan.Options
+	output    outputOptions
+	// store - история запусков, nil - не сохранять запуск
+	store *history.Store
+	// policy - политика для проверки результатов, nil - не проверять
+	policy *policy.Policy
+}
+
+// scanAction сканирует хосты из файла cfg.hostsFile и выводит результаты
+// в out. Если задана политика, её нарушения выводятся в errOut, а сама
+// функция возвращает ошибку с кодом завершения 1 при нарушениях
+// и 2 при ошибках сканирования
+func scanAction(ctx context.Context, out, errOut io.Writer, cfg scanConfig) error {
+	write, err := resultsWriter(cfg.output)
+	if err != nil {
+		return err
+	}
+
 	hl := &scan.HostsList{}
-	if err := hl.Load(hostsFile); err != nil {
+	if err := hl.Load(cfg.hostsFile); err != nil {
 		return err
 	}
 
-	results := scan.Run(hl, ports)
-	return printResults(out, results)
+	// Порты из политики сканируем всегда, иначе проверка будет неполной
+	ports := cfg.ports
+	if cfg.policy != nil {
+		ports = scan.MergePorts(ports, cfg.policy.Ports())
+	}
+
+	start := time.Now()
+	results, scanErr := scan.RunContext(ctx, hl, ports, cfg.opts)
+	if err := write(out, results); err != nil {
+		return err
+	}
+	if scanErr != nil {
+		fmt.Fprintf(errOut, "Сканирование прервано (%s), выведены частичные результаты\n", scanErr)
+
+		// При проверке политики прерванное сканирование - ошибка сканирования
+		code := interruptedCode
+		if cfg.policy != nil {
+			code = 2
+		}
+		return &exitError{code, fmt.Errorf("%w: %w", errInterrupted, scanErr)}
+	}
+
+	if cfg.store != nil {
+		_, err := cfg.store.Add(history.Run{
+			Time:      start,
+			HostsFile: cfg.hostsFile,
+			Ports:     ports,
+			Results:   results,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.policy != nil {
+		return checkPolicy(errOut, cfg.policy, results)
+	}
+	return nil
+}
+
+// checkPolicy выводит в out нарушения политики p и ошибки сканирования
+func checkPolicy(out io.Writer, p *policy.Policy, results []scan.Results) error {
+	rep := p.Evaluate(results)
+
+	message := ""
+	for _, e := range rep.Errors {
+		message += fmt.Sprintf("Ошибка сканирования: %s\n", e)
+	}
+	for _, v := range rep.Violations {
+		message += fmt.Sprintf("Нарушение политики: %s\n", v)
+	}
+	if _, err := fmt.Fprint(out, message); err != nil {
+		return err
+	}
+
+	switch {
+	case len(rep.Errors) > 0:
+		return &exitError{2, errScanErrors}
+	case len(rep.Violations) > 0:
+		return &exitError{1, errPolicyViolations}
+	default:
+		return nil
+	}
+}
+
+// resultsWriter возвращает функцию вывода результатов
+// в соответствии с параметрами вывода o
+func resultsWriter(o outputOptions) (func(io.Writer, []scan.Results) error, error) {
+	switch o.format {
+	case "", "text":
+		return printResults, nil
+	case "json":
+		return scan.WriteJSON, nil
+	case "ndjson":
+		return scan.WriteNDJSON, nil
+	case "xml":
+		return func(out io.Writer, results []scan.Results) error {
+			return scan.WriteNmapXML(out, results, commandLine())
+		}, nil
+	case "grepable":
+		return func(out io.Writer, results []scan.Results) error {
+			return scan.WriteNmapGrepable(out, results, commandLine())
+		}, nil
+	case "csv", "tsv":
+		comma := ','
+		if o.format == "tsv" {
+			comma = '\t'
+		}
+		return func(out io.Writer, results []scan.Results) error {
+			return scan.WriteCSV(out, results, o.columns, comma)
+		}, nil
+	case "prometheus":
+		return metrics.WriteTextfile, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnknownFormat, o.format)
+	}
+}
+
+// commandLine возвращает командную строку запуска pScan
+func commandLine() string {
+	return strings.Join(os.Args, " ")
 }
 
 func printResults(out io.Writer, results []scan.Results) error {
 	message := ""
 	for _, r := range results {
-		message += fmt.Sprintf("%s\n", r.Host)
+		message += fmt.Sprintf("%s\n", r.Target())
 
 		if r.NotFound {
 			message += "Хост не найден\n"
 		}
 
 		for _, p := range r.PortStates {
-			message += fmt.Sprintf("\t%d: %s\n", p.Port, p.Open)
+			// UDP порты отмечаются протоколом, TCP выводятся номером
+			port := strconv.Itoa(p.Port)
+			if p.Protocol != "" {
+				port += "/" + p.Protocol
+			}
+
+			if p.Status == scan.StatusError {
+				message += fmt.Sprintf("\t%s: %s (%s)\n", port, p.Status, p.Reason)
+				continue
+			}
+			if version := strings.TrimSpace(p.Product + " " + p.Version); version != "" {
+				message += fmt.Sprintf("\t%s: %s %s %s\n", port, p.Status, p.Service, version)
+			} else {
+				message += fmt.Sprintf("\t%s: %s\n", port, p.Status)
+			}
+
+			for _, line := range strings.Split(p.Banner, "\n") {
+				if line != "" {
+					message += fmt.Sprintf("\t\t%s\n", line)
+				}
+			}
+
+			if t := p.TLS; t != nil {
+				message += fmt.Sprintf("\t\t%s %s\n", t.Version, t.CipherSuite)
+				message += fmt.Sprintf("\t\tСубъект: %s\n", t.Subject)
+				if len(t.SANs) > 0 {
+					message += fmt.Sprintf("\t\tИмена: %s\n", strings.Join(t.SANs, ", "))
+				}
+				message += fmt.Sprintf("\t\tИздатель: %s\n", t.Issuer)
+				message += fmt.Sprintf("\t\tДействителен до: %s", t.NotAfter.Format(time.DateOnly))
+				if flags := t.Flags(); len(flags) > 0 {
+					message += fmt.Sprintf(" (%s)", strings.Join(flags, ", "))
+				}
+				message += "\n"
+			}
 		}
 	}
 
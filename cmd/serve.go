@@ -0,0 +1,369 @@
+/*
+Copyright © 2025 Vladimir Egorov
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"vegorov.ru/go-cli/pScan/api"
+	"vegorov.ru/go-cli/pScan/history"
+	"vegorov.ru/go-cli/pScan/metrics"
+	"vegorov.ru/go-cli/pScan/notify"
+	"vegorov.ru/go-cli/pScan/scan"
+	"vegorov.ru/go-cli/pScan/schedule"
+)
+
+// apiJob - имя задания в метриках сканирований, запущенных через API
+const apiJob = "api"
+
+// shutdownTimeout - время на завершение обработки запросов API
+const shutdownTimeout = 5 * time.Second
+
+var (
+	errNoJobs          = errors.New("в разделе jobs файла конфигурации не описано ни одного задания")
+	errUnknownNotifier = errors.New("неизвестный тип получателя уведомлений")
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Запустить сканирование по расписанию и HTTP API",
+	Long: `Запускает pScan в режиме службы: задания из раздела jobs файла
+конфигурации выполняются сразу и затем с заданным интервалом,
+а их результаты сохраняются в историю запусков. Если предыдущий
+запуск задания ещё не завершён, очередной пропускается.
+
+jobs:
+  - name: dmz
+    hosts-file: dmz.hosts
+    ports: "22,80,443"
+    interval: 15m
+
+С флагом --listen также запускается HTTP API для управления
+списком хостов и запуска сканирования. Описание API доступно
+по адресу /openapi.yaml, метрики Prometheus - по адресу /metrics.
+
+Об изменениях между последовательными запусками задания (открытие
+и закрытие порта, хост перестал разрешаться) сообщают получатели
+из раздела notifiers:
+
+notifiers:
+  - type: webhook
+    url: https://hooks.example.com/pscan
+    retries: 3
+    backoff: 5s
+  - type: command
+    command: logger -t pscan
+    events: [port-opened]
+  - type: maildrop
+    path: /var/mail/ops
+    to: ops@example.com`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listen := viper.GetString("listen")
+
+		jobs, err := loadJobs()
+		if err != nil && !(errors.Is(err, errNoJobs) && listen != "") {
+			return err
+		}
+
+		store, err := historyStore()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		s, err := schedule.New(jobs, store, os.Stderr)
+		if err != nil {
+			return err
+		}
+
+		notifier, err := loadNotifier(os.Stderr)
+		if err != nil {
+			return err
+		}
+		if err := seedNotifier(notifier, store); err != nil {
+			return err
+		}
+
+		collector := metrics.NewCollector()
+		s.OnRun = func(j schedule.Job, run history.Run) {
+			collector.Observe(j.Name, run.Results, time.Since(run.Time))
+			notifier.Handle(j.Name, run.Results)
+		}
+
+		var ln net.Listener
+		var srv *api.Server
+		if listen != "" {
+			ports, err := scan.ParsePorts(defaultPorts)
+			if err != nil {
+				return err
+			}
+
+			if ln, err = net.Listen("tcp", listen); err != nil {
+				return err
+			}
+			opts, err := scanOptions()
+			if err != nil {
+				return err
+			}
+
+			srv = api.New(ctx, viper.GetString("hosts-file"), ports, opts, store)
+			srv.OnScan = func(run history.Run) {
+				collector.Observe(apiJob, run.Results, time.Since(run.Time))
+			}
+		}
+
+		// Уведомления отправляются отдельно от заданий, чтобы
+		// медленный получатель не задерживал следующие запуски
+		notified := make(chan struct{})
+		go func() {
+			defer close(notified)
+			notifier.Run(ctx)
+		}()
+
+		err = serveAction(ctx, os.Stderr, s, ln, srv, collector)
+		stop()
+		<-notified
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().String("listen", "", "Адрес HTTP API, например localhost:8080")
+
+	viper.BindPFlag("listen", serveCmd.Flags().Lookup("listen"))
+}
+
+// jobConfig - описание задания в разделе jobs файла конфигурации
+type jobConfig struct {
+	Name      string        `mapstructure:"name"`
+	HostsFile string        `mapstructure:"hosts-file"`
+	Ports     string        `mapstructure:"ports"`
+	Interval  time.Duration `mapstructure:"interval"`
+}
+
+// loadJobs читает задания из раздела jobs файла конфигурации.
+// Если у задания не указан файл хостов или порты, используются
+// значения по умолчанию команды scan
+func loadJobs() ([]schedule.Job, error) {
+	var configs []jobConfig
+	if err := viper.UnmarshalKey("jobs", &configs); err != nil {
+		return nil, err
+	}
+	if len(configs) == 0 {
+		return nil, errNoJobs
+	}
+
+	opts, err := scanOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]schedule.Job, 0, len(configs))
+	for _, c := range configs {
+		if c.HostsFile == "" {
+			c.HostsFile = viper.GetString("hosts-file")
+		}
+		if c.Ports == "" {
+			c.Ports = defaultPorts
+		}
+
+		ports, err := scan.ParsePorts(c.Ports)
+		if err != nil {
+			return nil, fmt.Errorf("задание %s: %w", c.Name, err)
+		}
+
+		jobs = append(jobs, schedule.Job{
+			Name:      c.Name,
+			HostsFile: c.HostsFile,
+			Ports:     ports,
+			Interval:  c.Interval,
+			Options:   opts,
+		})
+	}
+	return jobs, nil
+}
+
+// notifierConfig - описание получателя в разделе notifiers
+// файла конфигурации
+type notifierConfig struct {
+	Type    string        `mapstructure:"type"`
+	Events  []string      `mapstructure:"events"`
+	URL     string        `mapstructure:"url"`
+	Retries int           `mapstructure:"retries"`
+	Backoff time.Duration `mapstructure:"backoff"`
+	Command string        `mapstructure:"command"`
+	Path    string        `mapstructure:"path"`
+	From    string        `mapstructure:"from"`
+	To      string        `mapstructure:"to"`
+}
+
+// loadNotifier возвращает уведомитель с получателями из раздела
+// notifiers файла конфигурации
+func loadNotifier(logOut io.Writer) (*notify.Notifier, error) {
+	var configs []notifierConfig
+	if err := viper.UnmarshalKey("notifiers", &configs); err != nil {
+		return nil, err
+	}
+
+	n := notify.New(logOut)
+	for _, c := range configs {
+		var sink notify.Sink
+		switch c.Type {
+		case "webhook":
+			sink = &notify.Webhook{URL: c.URL, Retries: c.Retries, Backoff: c.Backoff}
+		case "command":
+			sink = &notify.Command{Shell: c.Command}
+		case "maildrop":
+			sink = &notify.MailDrop{Path: c.Path, From: c.From, To: c.To}
+		default:
+			return nil, fmt.Errorf("%w: %q", errUnknownNotifier, c.Type)
+		}
+
+		if err := n.AddSink(sink, c.Events...); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// seedNotifier передаёт уведомителю n результаты последнего
+// запуска каждого задания из истории, чтобы изменения
+// отслеживались и после перезапуска pScan serve
+func seedNotifier(n *notify.Notifier, store *history.Store) error {
+	runs, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		if run.Job != "" {
+			n.Seed(run.Job, run.Results)
+		}
+	}
+	return nil
+}
+
+// scanOptions возвращает параметры сканирования из конфигурации
+func scanOptions() (scan.Options, error) {
+	ipVersion, err := scan.ParseIPVersion(viper.GetString("ip-version"))
+	if err != nil {
+		return scan.Options{}, err
+	}
+
+	return scan.Options{
+		Timeout:  viper.GetDuration("timeout"),
+		Retries:  viper.GetInt("retries"),
+		Backoff:  viper.GetDuration("backoff"),
+		MaxHosts: viper.GetInt("max-hosts"),
+
+		Banner:        viper.GetBool("banner"),
+		BannerTimeout: viper.GetDuration("banner-timeout"),
+
+		ServiceDetect: viper.GetBool("service-detect"),
+		ProbeTimeout:  viper.GetDuration("probe-timeout"),
+
+		TLSInspect:    viper.GetBool("tls"),
+		ExpiryWarning: viper.GetDuration("expiry-warning"),
+
+		Rate:            viper.GetFloat64("rate"),
+		HostConcurrency: viper.GetInt("host-concurrency"),
+		Jitter:          viper.GetDuration("jitter"),
+
+		// Без заданного seed каждое сканирование задания или API
+		// проверяет порты в новом случайном порядке
+		Randomize:  viper.GetBool("randomize"),
+		Seed:       viper.GetUint64("seed"),
+		RandomSeed: !viper.IsSet("seed"),
+
+		IPVersion: ipVersion,
+		UDP:       viper.GetBool("udp"),
+	}, nil
+}
+
+// serveAction выполняет задания планировщика s до отмены ctx.
+// Если ln не nil, на нём обслуживаются запросы HTTP API srv
+// и метрики сборщика m по адресу /metrics
+func serveAction(ctx context.Context, out io.Writer, s *schedule.Scheduler,
+	ln net.Listener, srv *api.Server, m *metrics.Collector) error {
+	for _, j := range s.Jobs() {
+		fmt.Fprintf(out, "Задание %s: %s каждые %s\n", j.Name, j.HostsFile, j.Interval)
+	}
+
+	if ln == nil {
+		s.Run(ctx)
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", srv.Handler())
+	mux.Handle("GET /metrics", m)
+
+	hs := &http.Server{Handler: mux}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- hs.Serve(ln)
+	}()
+	fmt.Fprintf(out, "HTTP API: http://%s\n", ln.Addr())
+
+	// Ошибка HTTP сервера останавливает и планировщик
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancelShutdown()
+		err = hs.Shutdown(shutdownCtx)
+	case err = <-errCh:
+		fmt.Fprintf(out, "Ошибка HTTP сервера: %s\n", err)
+		cancel()
+	}
+
+	// Запущенные задания прерываются вместе с ctx
+	<-done
+	srv.Close()
+	return err
+}
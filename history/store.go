@@ -0,0 +1,243 @@
+// Package history хранит результаты прошлых запусков сканирования
+// в локальном файле формата JSON Lines: по одному запуску в строке.
+// Новые запуски только дописываются в конец файла, а файл целиком
+// переписывается лишь при удалении старых запусков
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+// fileName - имя файла истории в каталоге хранилища
+const fileName = "runs.jsonl"
+
+// idLayout - формат идентификатора запуска, основанного на его времени
+const idLayout = "20060102-150405.000000"
+
+var ErrNotFound = errors.New("запуск не найден в истории")
+
+// Run - один запуск сканирования
+type Run struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Job - имя задания для запусков по расписанию в режиме pScan serve
+	Job       string         `json:"job,omitempty"`
+	HostsFile string         `json:"hosts_file"`
+	Ports     []int          `json:"ports"`
+	Results   []scan.Results `json:"results"`
+}
+
+// Store - хранилище истории запусков в каталоге dir
+type Store struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// DefaultDir возвращает каталог данных пользователя для истории:
+// $XDG_DATA_HOME/pScan, %LOCALAPPDATA%\pScan в Windows
+// или ~/.local/share/pScan
+func DefaultDir() (string, error) {
+	if d := os.Getenv("XDG_DATA_HOME"); d != "" {
+		return filepath.Join(d, "pScan"), nil
+	}
+
+	if runtime.GOOS == "windows" {
+		d, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(d, "pScan"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "pScan"), nil
+}
+
+// NewStore возвращает хранилище истории в каталоге dir.
+// Каталог создаётся при первой записи
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// path возвращает путь к файлу истории
+func (s *Store) path() string {
+	return filepath.Join(s.dir, fileName)
+}
+
+// Add дописывает запуск в историю. Пустые ID и время запуска
+// заполняются текущим временем. Возвращает сохранённый запуск
+func (s *Store) Add(run Run) (Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if run.Time.IsZero() {
+		run.Time = time.Now()
+	}
+	if run.ID == "" {
+		run.ID = run.Time.UTC().Format(idLayout)
+	}
+
+	data, err := json.Marshal(run)
+	if err != nil {
+		return Run{}, err
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return Run{}, err
+	}
+
+	f, err := os.OpenFile(s.path(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return Run{}, err
+	}
+
+	// Строка записывается одним вызовом Write, чтобы параллельные
+	// процессы не перемешали содержимое
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return Run{}, err
+	}
+	return run, f.Close()
+}
+
+// List возвращает все запуски из истории в порядке их добавления
+func (s *Store) List() ([]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load()
+}
+
+// load читает файл истории. Отсутствие файла означает пустую историю
+func (s *Store) load() ([]Run, error) {
+	f, err := os.Open(s.path())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []Run
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if len(data) > 0 {
+			var run Run
+			if jsonErr := json.Unmarshal(data, &run); jsonErr != nil {
+				return nil, fmt.Errorf("%s:%d: %w", s.path(), line, jsonErr)
+			}
+			runs = append(runs, run)
+		}
+		if errors.Is(err, io.EOF) {
+			return runs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Get возвращает запуск с идентификатором id
+func (s *Store) Get(id string) (Run, error) {
+	runs, err := s.List()
+	if err != nil {
+		return Run{}, err
+	}
+
+	for _, run := range runs {
+		if run.ID == id {
+			return run, nil
+		}
+	}
+	return Run{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// Latest возвращает последний добавленный запуск
+func (s *Store) Latest() (Run, error) {
+	runs, err := s.List()
+	if err != nil {
+		return Run{}, err
+	}
+	if len(runs) == 0 {
+		return Run{}, ErrNotFound
+	}
+	return runs[len(runs)-1], nil
+}
+
+// Prune удаляет из истории запуски старше olderThan и оставляет
+// не более keep последних запусков. Нулевое значение параметра
+// отключает соответствующее условие. Возвращает количество
+// удалённых запусков
+func (s *Store) Prune(olderThan time.Duration, keep int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+
+	kept := runs
+	if olderThan > 0 {
+		cutoff := time.Now().Add(-olderThan)
+		kept = kept[:0:0]
+		for _, run := range runs {
+			if !run.Time.Before(cutoff) {
+				kept = append(kept, run)
+			}
+		}
+	}
+	if keep > 0 && len(kept) > keep {
+		kept = kept[len(kept)-keep:]
+	}
+
+	removed := len(runs) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	// Переписываем историю во временный файл и подменяем им
+	// исходный, чтобы не потерять данные при сбое
+	tmp, err := os.CreateTemp(s.dir, fileName+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, run := range kept {
+		if err := enc.Encode(run); err != nil {
+			tmp.Close()
+			return 0, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(tmp.Name(), s.path()); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
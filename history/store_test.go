@@ -0,0 +1,137 @@
+package history_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/history"
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+// addRuns добавляет в хранилище запуски с указанным временем
+func addRuns(t *testing.T, s *history.Store, times ...time.Time) []history.Run {
+	t.Helper()
+
+	runs := []history.Run{}
+	for _, ts := range times {
+		run, err := s.Add(history.Run{
+			Time:      ts,
+			HostsFile: "pScan.hosts",
+			Ports:     []int{22, 80},
+			Results: []scan.Results{
+				{
+					Host:      "localhost",
+					ScannedAt: ts,
+					PortStates: []scan.PortState{
+						{Port: 22, Open: true, Status: scan.StatusOpen},
+						{Port: 80, Status: scan.StatusClosed},
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Ошибка добавления запуска: %q\n", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+func TestAddList(t *testing.T) {
+	s := history.NewStore(t.TempDir())
+
+	runs, err := s.List()
+	if err != nil || len(runs) != 0 {
+		t.Fatalf("Ожидали пустую историю, а получили %d запусков, ошибка: %v\n", len(runs), err)
+	}
+
+	now := time.Now()
+	added := addRuns(t, s, now.Add(-time.Hour), now)
+
+	if added[0].ID == "" || added[0].ID == added[1].ID {
+		t.Errorf("Ожидали уникальные идентификаторы, а получили %q и %q\n", added[0].ID, added[1].ID)
+	}
+
+	runs, err = s.List()
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	if len(runs) != 2 {
+		t.Fatalf("Ожидали 2 запуска, а получили %d\n", len(runs))
+	}
+
+	if runs[1].ID != added[1].ID || runs[1].HostsFile != "pScan.hosts" || len(runs[1].Ports) != 2 {
+		t.Errorf("Неверные данные запуска: %+v\n", runs[1])
+	}
+
+	ps := runs[1].Results[0].PortStates
+	if len(ps) != 2 || ps[0].Status != scan.StatusOpen || ps[1].Status != scan.StatusClosed {
+		t.Errorf("Неверные состояния портов: %+v\n", ps)
+	}
+}
+
+func TestGet(t *testing.T) {
+	s := history.NewStore(t.TempDir())
+	added := addRuns(t, s, time.Now())
+
+	run, err := s.Get(added[0].ID)
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+	if run.ID != added[0].ID {
+		t.Errorf("Ожидали запуск %q, а получили %q\n", added[0].ID, run.ID)
+	}
+
+	if _, err := s.Get("no-such-run"); !errors.Is(err, history.ErrNotFound) {
+		t.Errorf("Ожидали ошибку %q, а получили %v\n", history.ErrNotFound, err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name        string
+		olderThan   time.Duration
+		keep        int
+		expectedLen int
+	}{
+		{"Nothing", 0, 0, 4},
+		{"ByAge", 36 * time.Hour, 0, 2},
+		{"ByCount", 0, 1, 1},
+		{"ByAgeAndCount", 72 * time.Hour, 2, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := history.NewStore(t.TempDir())
+			added := addRuns(t, s,
+				now.Add(-96*time.Hour), now.Add(-48*time.Hour), now.Add(-24*time.Hour), now)
+
+			removed, err := s.Prune(tc.olderThan, tc.keep)
+			if err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+			}
+
+			if removed != len(added)-tc.expectedLen {
+				t.Errorf("Ожидали удаление %d запусков, а удалено %d\n", len(added)-tc.expectedLen, removed)
+			}
+
+			runs, err := s.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(runs) != tc.expectedLen {
+				t.Fatalf("Ожидали %d запусков, а получили %d\n", tc.expectedLen, len(runs))
+			}
+
+			// Всегда остаются самые новые запуски
+			if runs[len(runs)-1].ID != added[len(added)-1].ID {
+				t.Errorf("Ожидали последним запуск %q, а получили %q\n", added[len(added)-1].ID, runs[len(runs)-1].ID)
+			}
+		})
+	}
+}
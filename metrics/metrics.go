@@ -0,0 +1,325 @@
+// Package metrics формирует метрики Prometheus по результатам
+// сканирования в текстовом формате экспозиции
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+// DurationBuckets - границы корзин гистограммы длительности
+// сканирования в секундах
+var DurationBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 600, 1800}
+
+// jobLabel - имя метки задания сканирования. Метку job Prometheus
+// присваивает цели сбора метрик, поэтому она не используется
+const jobLabel = "scan_job"
+
+// label - метка образца метрики
+type label struct {
+	name, value string
+}
+
+// sample - образец метрики
+type sample struct {
+	suffix string
+	labels []label
+	value  float64
+}
+
+// labelEscaper экранирует значения меток
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeFamily записывает семейство метрик name типа typ
+func writeFamily(b *strings.Builder, name, typ, help string, samples []sample) {
+	if len(samples) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, typ)
+
+	for _, s := range samples {
+		b.WriteString(name + s.suffix)
+		if len(s.labels) > 0 {
+			b.WriteByte('{')
+			for i, l := range s.labels {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				fmt.Fprintf(b, `%s="%s"`, l.name, labelEscaper.Replace(l.value))
+			}
+			b.WriteByte('}')
+		}
+		b.WriteString(" " + formatValue(s.value) + "\n")
+	}
+}
+
+// formatValue форматирует значение образца
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// boolValue возвращает 1 для true и 0 для false
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// hostLabels возвращает метки хоста, просканированного заданием job.
+// Метка задания добавляется, если оно указано, а метка ip - если
+// адреса хоста сканировались по отдельности
+func hostLabels(job string, r scan.Results) []label {
+	var labels []label
+	if job != "" {
+		labels = append(labels, label{jobLabel, job})
+	}
+	labels = append(labels, label{"host", r.Host})
+	if r.IP != "" {
+		labels = append(labels, label{"ip", r.IP})
+	}
+	return labels
+}
+
+// maxBannerLabel - наибольшая длина метки banner в символах
+const maxBannerLabel = 64
+
+// bannerLabel возвращает значение метки banner: первое слово
+// приветствия, обрезанное до maxBannerLabel символов. Остальная
+// часть приветствия часто содержит время или идентификатор сессии
+// и порождала бы новый ряд при каждом сканировании
+func bannerLabel(banner string) string {
+	fields := strings.Fields(banner)
+	if len(fields) == 0 {
+		return ""
+	}
+	r := []rune(fields[0])
+	return string(r[:min(len(r), maxBannerLabel)])
+}
+
+// jobResults - результаты последнего сканирования задания
+type jobResults struct {
+	job     string
+	results []scan.Results
+}
+
+// writeResults записывает метрики состояния хостов и портов.
+// Метка banner добавляется, только если задан withBanner
+func writeResults(b *strings.Builder, runs []jobResults, withBanner bool) {
+	var resolvable, scanned, open, latency, banner, certExpiry []sample
+
+	for _, run := range runs {
+		for _, r := range run.results {
+			host := hostLabels(run.job, r)
+			resolvable = append(resolvable, sample{labels: host, value: boolValue(!r.NotFound)})
+
+			if !r.ScannedAt.IsZero() {
+				scanned = append(scanned, sample{
+					labels: host,
+					value:  float64(r.ScannedAt.UnixMilli()) / 1000,
+				})
+			}
+
+			for _, p := range r.PortStates {
+				labels := append(hostLabels(run.job, r), label{"port", strconv.Itoa(p.Port)})
+				if p.Protocol != "" {
+					labels = append(labels, label{"protocol", p.Protocol})
+				}
+				open = append(open, sample{labels: labels, value: boolValue(p.Status == scan.StatusOpen)})
+				if p.Status == scan.StatusOpen {
+					latency = append(latency, sample{labels: labels, value: p.Latency.Seconds()})
+				}
+				if p.TLS != nil {
+					certExpiry = append(certExpiry, sample{
+						labels: labels,
+						value:  float64(p.TLS.NotAfter.Unix()),
+					})
+				}
+				if p.Banner != "" {
+					bl := labels
+					if withBanner {
+						bl = append(slices.Clip(labels), label{"banner", bannerLabel(p.Banner)})
+					}
+					banner = append(banner, sample{labels: bl, value: 1})
+				}
+			}
+		}
+	}
+
+	writeFamily(b, "pscan_host_resolvable", "gauge",
+		"Имя хоста разрешается (1) или нет (0)", resolvable)
+	writeFamily(b, "pscan_port_open", "gauge",
+		"Порт открыт (1) или нет (0)", open)
+	writeFamily(b, "pscan_port_latency_seconds", "gauge",
+		"Время установки соединения с открытым портом", latency)
+	writeFamily(b, "pscan_port_banner_info", "gauge",
+		"Сервис на открытом порту отправил приветствие", banner)
+	writeFamily(b, "pscan_tls_cert_not_after_seconds", "gauge",
+		"Время истечения срока действия сертификата сервера", certExpiry)
+	writeFamily(b, "pscan_last_scan_timestamp_seconds", "gauge",
+		"Время последнего сканирования хоста", scanned)
+}
+
+// WriteTextfile записывает результаты сканирования в формате
+// экспозиции Prometheus для textfile коллектора node_exporter
+func WriteTextfile(out io.Writer, results []scan.Results) error {
+	var b strings.Builder
+	writeResults(&b, []jobResults{{results: results}}, true)
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// histogram - гистограмма длительности сканирования
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// dialKey - метки счётчика ошибок соединения
+type dialKey struct {
+	job, host, ip, state string
+}
+
+// Collector накапливает метрики сканирований и отдаёт их
+// по HTTP в формате экспозиции Prometheus
+type Collector struct {
+	mu         sync.Mutex
+	results    map[string][]scan.Results
+	durations  map[string]*histogram
+	dialErrors map[dialKey]uint64
+}
+
+// NewCollector возвращает пустой сборщик метрик
+func NewCollector() *Collector {
+	return &Collector{
+		results:    make(map[string][]scan.Results),
+		durations:  make(map[string]*histogram),
+		dialErrors: make(map[dialKey]uint64),
+	}
+}
+
+// Observe учитывает результаты сканирования results задания job,
+// выполненного за d. Состояние хостов задания целиком заменяется
+// последним полученным, так что исчезнувшие из его списка хосты
+// пропадают из метрик. Ошибки соединения и длительность накапливаются
+func (c *Collector) Observe(job string, results []scan.Results, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	h, ok := c.durations[job]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(DurationBuckets))}
+		c.durations[job] = h
+	}
+	for i, le := range DurationBuckets {
+		if d.Seconds() <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += d.Seconds()
+	h.count++
+
+	c.results[job] = results
+	for _, r := range results {
+		for _, p := range r.PortStates {
+			if p.Status == scan.StatusFiltered || p.Status == scan.StatusError {
+				c.dialErrors[dialKey{job, r.Host, r.IP, p.Status.String()}]++
+			}
+		}
+	}
+}
+
+// WriteTo записывает накопленные метрики в out
+func (c *Collector) WriteTo(out io.Writer) (int64, error) {
+	c.mu.Lock()
+	var b strings.Builder
+
+	jobs := make([]string, 0, len(c.durations))
+	for j := range c.durations {
+		jobs = append(jobs, j)
+	}
+	slices.Sort(jobs)
+
+	runs := make([]jobResults, 0, len(jobs))
+	for _, j := range jobs {
+		runs = append(runs, jobResults{j, c.results[j]})
+	}
+	// Приветствия не попадают в метки: при постоянном сборе метрик
+	// каждое новое приветствие создавало бы новый ряд
+	writeResults(&b, runs, false)
+
+	keys := make([]dialKey, 0, len(c.dialErrors))
+	for k := range c.dialErrors {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b dialKey) int {
+		if c := strings.Compare(a.job, b.job); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.host, b.host); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.ip, b.ip); c != 0 {
+			return c
+		}
+		return strings.Compare(a.state, b.state)
+	})
+
+	errSamples := make([]sample, 0, len(keys))
+	for _, k := range keys {
+		errSamples = append(errSamples, sample{
+			labels: append(hostLabels(k.job, scan.Results{Host: k.host, IP: k.ip}), label{"state", k.state}),
+			value:  float64(c.dialErrors[k]),
+		})
+	}
+	writeFamily(&b, "pscan_dial_errors_total", "counter",
+		"Проверки портов, завершившиеся ошибкой соединения", errSamples)
+
+	var durSamples []sample
+	for _, j := range jobs {
+		h := c.durations[j]
+		for i, le := range DurationBuckets {
+			durSamples = append(durSamples, sample{
+				suffix: "_bucket",
+				labels: []label{{jobLabel, j}, {"le", formatValue(le)}},
+				value:  float64(h.counts[i]),
+			})
+		}
+		durSamples = append(durSamples,
+			sample{suffix: "_bucket", labels: []label{{jobLabel, j}, {"le", "+Inf"}}, value: float64(h.count)},
+			sample{suffix: "_sum", labels: []label{{jobLabel, j}}, value: h.sum},
+			sample{suffix: "_count", labels: []label{{jobLabel, j}}, value: float64(h.count)},
+		)
+	}
+	writeFamily(&b, "pscan_scan_duration_seconds", "histogram",
+		"Длительность сканирования задания", durSamples)
+	c.mu.Unlock()
+
+	n, err := io.WriteString(out, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP отдаёт накопленные метрики
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
@@ -0,0 +1,185 @@
+package metrics_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/metrics"
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+func testResults() []scan.Results {
+	ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []scan.Results{
+		{
+			Host:      "localhost",
+			Addresses: []string{"127.0.0.1"},
+			ScannedAt: ts,
+			PortStates: []scan.PortState{
+				{Port: 22, Open: true, Status: scan.StatusOpen, Latency: 1500 * time.Microsecond,
+					Banner: "SSH-2.0-OpenSSH_9.6\nhello"},
+				{Port: 25, Status: scan.StatusClosed, Latency: time.Millisecond},
+				{Port: 443, Open: true, Status: scan.StatusOpen, Latency: time.Millisecond,
+					TLS: &scan.TLSInfo{NotAfter: ts.AddDate(1, 0, 0)}},
+				{Port: 81, Status: scan.StatusFiltered, Latency: time.Second},
+			},
+		},
+		{
+			Host:      `bad"host`,
+			NotFound:  true,
+			ScannedAt: ts,
+		},
+	}
+}
+
+func TestWriteTextfile(t *testing.T) {
+	var out bytes.Buffer
+	if err := metrics.WriteTextfile(&out, testResults()); err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	expected := `# HELP pscan_host_resolvable Имя хоста разрешается (1) или нет (0)
+# TYPE pscan_host_resolvable gauge
+pscan_host_resolvable{host="localhost"} 1
+pscan_host_resolvable{host="bad\"host"} 0
+# HELP pscan_port_open Порт открыт (1) или нет (0)
+# TYPE pscan_port_open gauge
+pscan_port_open{host="localhost",port="22"} 1
+pscan_port_open{host="localhost",port="25"} 0
+pscan_port_open{host="localhost",port="443"} 1
+pscan_port_open{host="localhost",port="81"} 0
+# HELP pscan_port_latency_seconds Время установки соединения с открытым портом
+# TYPE pscan_port_latency_seconds gauge
+pscan_port_latency_seconds{host="localhost",port="22"} 0.0015
+pscan_port_latency_seconds{host="localhost",port="443"} 0.001
+# HELP pscan_port_banner_info Сервис на открытом порту отправил приветствие
+# TYPE pscan_port_banner_info gauge
+pscan_port_banner_info{host="localhost",port="22",banner="SSH-2.0-OpenSSH_9.6"} 1
+# HELP pscan_tls_cert_not_after_seconds Время истечения срока действия сертификата сервера
+# TYPE pscan_tls_cert_not_after_seconds gauge
+pscan_tls_cert_not_after_seconds{host="localhost",port="443"} 1.767323045e+09
+# HELP pscan_last_scan_timestamp_seconds Время последнего сканирования хоста
+# TYPE pscan_last_scan_timestamp_seconds gauge
+pscan_last_scan_timestamp_seconds{host="localhost"} 1.735787045e+09
+pscan_last_scan_timestamp_seconds{host="bad\"host"} 1.735787045e+09
+`
+	if out.String() != expected {
+		t.Errorf("Ожидали:\n%s\nполучили:\n%s\n", expected, out.String())
+	}
+}
+
+func TestWriteTextfileAddresses(t *testing.T) {
+	results := []scan.Results{
+		{Host: "host1", IP: "192.0.2.1", PortStates: []scan.PortState{{Port: 22, Status: scan.StatusOpen}}},
+		{Host: "host1", IP: "2001:db8::1", PortStates: []scan.PortState{{Port: 22, Status: scan.StatusClosed}}},
+	}
+
+	var out bytes.Buffer
+	if err := metrics.WriteTextfile(&out, results); err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	for _, line := range []string{
+		`pscan_port_open{host="host1",ip="192.0.2.1",port="22"} 1`,
+		`pscan_port_open{host="host1",ip="2001:db8::1",port="22"} 0`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Ожидали строку %s, а получили:\n%s\n", line, out.String())
+		}
+	}
+}
+
+func TestWriteTextfileBannerLabel(t *testing.T) {
+	testCases := []struct {
+		name     string
+		banner   string
+		expected string
+	}{
+		{"FirstWord", "220 mail.example.com ESMTP ready at 12:00:01", "220"},
+		{"LeadingSpace", "  SSH-2.0-OpenSSH_9.6 Ubuntu", "SSH-2.0-OpenSSH_9.6"},
+		{"Truncated", strings.Repeat("я", 100), strings.Repeat("я", 64)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := []scan.Results{{Host: "host1", PortStates: []scan.PortState{
+				{Port: 25, Open: true, Status: scan.StatusOpen, Banner: tc.banner},
+			}}}
+
+			var out bytes.Buffer
+			if err := metrics.WriteTextfile(&out, results); err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+			}
+
+			line := `pscan_port_banner_info{host="host1",port="25",banner="` + tc.expected + `"} 1`
+			if !strings.Contains(out.String(), line+"\n") {
+				t.Errorf("Ожидали строку %s, а получили:\n%s\n", line, out.String())
+			}
+		})
+	}
+}
+
+func TestCollector(t *testing.T) {
+	c := metrics.NewCollector()
+	c.Observe("dmz", testResults(), 2*time.Second)
+
+	// Последние результаты задания целиком заменяют предыдущие:
+	// хост bad"host и порт 25 пропадают из метрик задания dmz
+	last := testResults()[:1]
+	last[0].PortStates = slices.DeleteFunc(slices.Clone(last[0].PortStates),
+		func(p scan.PortState) bool { return p.Port == 25 })
+	c.Observe("dmz", last, 45*time.Second)
+
+	// Результаты другого задания не заменяют результаты dmz
+	closed := []scan.Results{{Host: "localhost", PortStates: []scan.PortState{{Port: 22}}}}
+	c.Observe("api", closed, 50*time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Неверный Content-Type: %q\n", ct)
+	}
+
+	body := rec.Body.String()
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"PortOpen", "pscan_port_open{scan_job=\"dmz\",host=\"localhost\",port=\"22\"} 1\n"},
+		{"OtherJobPortOpen", "pscan_port_open{scan_job=\"api\",host=\"localhost\",port=\"22\"} 0\n"},
+		{"Resolvable", "pscan_host_resolvable{scan_job=\"dmz\",host=\"localhost\"} 1\n"},
+		{"BannerWithoutText", "pscan_port_banner_info{scan_job=\"dmz\",host=\"localhost\",port=\"22\"} 1\n"},
+		{"DialErrors", "pscan_dial_errors_total{scan_job=\"dmz\",host=\"localhost\",state=\"filtered\"} 2\n"},
+		{"CounterType", "# TYPE pscan_dial_errors_total counter\n"},
+		{"HistogramType", "# TYPE pscan_scan_duration_seconds histogram\n"},
+		{"BucketLow", "pscan_scan_duration_seconds_bucket{scan_job=\"dmz\",le=\"1\"} 0\n"},
+		{"BucketMid", "pscan_scan_duration_seconds_bucket{scan_job=\"dmz\",le=\"5\"} 1\n"},
+		{"BucketHigh", "pscan_scan_duration_seconds_bucket{scan_job=\"dmz\",le=\"60\"} 2\n"},
+		{"BucketInf", "pscan_scan_duration_seconds_bucket{scan_job=\"dmz\",le=\"+Inf\"} 2\n"},
+		{"Sum", "pscan_scan_duration_seconds_sum{scan_job=\"dmz\"} 47\n"},
+		{"Count", "pscan_scan_duration_seconds_count{scan_job=\"dmz\"} 2\n"},
+		{"OtherJob", "pscan_scan_duration_seconds_bucket{scan_job=\"api\",le=\"0.1\"} 1\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if !strings.Contains(body, tc.expected) {
+				t.Errorf("Ожидали строку %q в выводе:\n%s\n", tc.expected, body)
+			}
+		})
+	}
+
+	for _, stale := range []string{
+		"pscan_port_open{scan_job=\"dmz\",host=\"localhost\",port=\"25\"}",
+		"pscan_host_resolvable{scan_job=\"dmz\",host=\"bad\\\"host\"}",
+	} {
+		if strings.Contains(body, stale) {
+			t.Errorf("Не ожидали %s после замены результатов задания\n", stale)
+		}
+	}
+}
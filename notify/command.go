@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Command выполняет команду оболочки, передавая ей событие
+// в формате JSON на стандартный ввод. Тип события, хост и порт
+// также доступны в переменных окружения PSCAN_EVENT, PSCAN_HOST
+// и PSCAN_PORT
+type Command struct {
+	Shell string
+}
+
+// Notify выполняет команду для события e
+func (c *Command) Notify(ctx context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Shell)
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	cmd.Env = append(os.Environ(),
+		"PSCAN_EVENT="+e.Type,
+		"PSCAN_HOST="+e.Host,
+		"PSCAN_PORT="+strconv.Itoa(e.Port),
+	)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %w: %s", c.Shell, err, msg)
+		}
+		return fmt.Errorf("%s: %w", c.Shell, err)
+	}
+	return nil
+}
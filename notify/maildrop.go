@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMailFrom - отправитель писем по умолчанию
+const DefaultMailFrom = "pscan@localhost"
+
+// MailDrop дописывает письмо о событии в почтовый ящик
+// в формате mbox, например, /var/mail/<пользователь>
+type MailDrop struct {
+	Path string
+	From string
+	To   string
+
+	mu sync.Mutex
+}
+
+// Notify дописывает письмо о событии e в файл Path
+func (m *MailDrop) Notify(ctx context.Context, e Event) error {
+	msg, err := m.message(e)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(msg); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// message возвращает письмо о событии e с разделителем mbox
+func (m *MailDrop) message(e Event) (string, error) {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	from := m.From
+	if from == "" {
+		from = DefaultMailFrom
+	}
+
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From %s %s\n", from, t.UTC().Format(time.ANSIC))
+	fmt.Fprintf(&b, "From: %s\n", from)
+	if m.To != "" {
+		fmt.Fprintf(&b, "To: %s\n", m.To)
+	}
+	fmt.Fprintf(&b, "Date: %s\n", t.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Subject: %s\n", mime.QEncoding.Encode("utf-8", "pScan: "+e.String()))
+	b.WriteString("MIME-Version: 1.0\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\n\n")
+
+	body := e.String() + "\n\n" + string(data) + "\n"
+	for _, line := range strings.SplitAfter(body, "\n") {
+		// Строки тела, похожие на разделитель mbox, экранируются
+		if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+			b.WriteByte('>')
+		}
+		b.WriteString(line)
+	}
+	b.WriteString("\n")
+
+	return b.String(), nil
+}
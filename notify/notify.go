@@ -0,0 +1,213 @@
+// Package notify отправляет уведомления об изменении состояния
+// хостов и портов между последовательными сканированиями
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+// Типы событий
+const (
+	PortOpened       = "port-opened"
+	PortClosed       = "port-closed"
+	HostUnresolvable = "host-unresolvable"
+)
+
+// EventTypes - все типы событий
+var EventTypes = []string{PortOpened, PortClosed, HostUnresolvable}
+
+var ErrUnknownEvent = errors.New("неизвестный тип события")
+
+// Event - изменение состояния хоста или порта
+type Event struct {
+	Type string    `json:"type"`
+	Job  string    `json:"job,omitempty"`
+	Time time.Time `json:"time"`
+	Host string    `json:"host"`
+	// Port, From и To заполняются для событий портов
+	Port    int          `json:"port,omitempty"`
+	From    *scan.Status `json:"from,omitempty"`
+	To      *scan.Status `json:"to,omitempty"`
+	Service string       `json:"service,omitempty"`
+}
+
+// String возвращает краткое описание события
+func (e Event) String() string {
+	switch e.Type {
+	case PortOpened:
+		return fmt.Sprintf("%s:%d открыт (было: %s)", e.Host, e.Port, e.From)
+	case PortClosed:
+		return fmt.Sprintf("%s:%d %s (был открыт)", e.Host, e.Port, e.To)
+	case HostUnresolvable:
+		return fmt.Sprintf("%s: имя не разрешается", e.Host)
+	}
+	return fmt.Sprintf("%s: %s", e.Host, e.Type)
+}
+
+// Events возвращает события задания job, произошедшие между
+// сканированиями before и after. t - время события
+func Events(job string, before, after []scan.Results, t time.Time) []Event {
+	d := scan.Compare(before, after)
+
+	events := []Event{}
+	portEvent := func(typ string, c scan.PortChange) Event {
+		return Event{
+			Type:    typ,
+			Job:     job,
+			Time:    t,
+			Host:    c.Host,
+			Port:    c.Port,
+			From:    &c.From,
+			To:      &c.To,
+			Service: c.NewService,
+		}
+	}
+
+	for _, c := range d.Opened {
+		events = append(events, portEvent(PortOpened, c))
+	}
+	for _, c := range d.Closed {
+		c.NewService = c.OldService
+		events = append(events, portEvent(PortClosed, c))
+	}
+	for _, h := range d.Unresolvable {
+		events = append(events, Event{Type: HostUnresolvable, Job: job, Time: t, Host: h})
+	}
+
+	return events
+}
+
+// QueueSize - наибольшее количество событий, ожидающих отправки
+// в один получатель. События сверх него пропускаются
+const QueueSize = 100
+
+// Sink - получатель уведомлений
+type Sink interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Notifier сравнивает результаты последовательных сканирований
+// каждого задания и отправляет события в получатели. Каждый
+// получатель обслуживает своя очередь, поэтому медленный получатель
+// не задерживает ни сканирование, ни другие получатели
+type Notifier struct {
+	mu    sync.Mutex
+	sinks []sinkFilter
+	last  map[string][]scan.Results
+
+	logger *log.Logger
+}
+
+// sinkFilter - получатель, типы событий, которые он получает,
+// и очередь событий, ожидающих отправки
+type sinkFilter struct {
+	sink   Sink
+	events []string
+	queue  chan Event
+}
+
+// New возвращает уведомитель без получателей. События и ошибки
+// отправки уведомлений записываются в logOut
+func New(logOut io.Writer) *Notifier {
+	return &Notifier{
+		last:   make(map[string][]scan.Results),
+		logger: log.New(logOut, "", log.LstdFlags),
+	}
+}
+
+// AddSink добавляет получатель событий типов events.
+// Если events пуст, получатель получает все события.
+// Получатели добавляются до вызова Run
+func (n *Notifier) AddSink(s Sink, events ...string) error {
+	for _, e := range events {
+		if !slices.Contains(EventTypes, e) {
+			return fmt.Errorf("%w: %s", ErrUnknownEvent, e)
+		}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sinks = append(n.sinks, sinkFilter{sink: s, events: events, queue: make(chan Event, QueueSize)})
+	return nil
+}
+
+// Seed задаёт результаты предыдущего сканирования задания job,
+// например, последнего запуска из истории
+func (n *Notifier) Seed(job string, results []scan.Results) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.last[job] = results
+}
+
+// Handle сравнивает результаты results задания job с предыдущими
+// и ставит события в очереди получателей, не дожидаясь отправки.
+// Первые результаты задания только запоминаются. Возвращает
+// найденные события
+func (n *Notifier) Handle(job string, results []scan.Results) []Event {
+	n.mu.Lock()
+	before, ok := n.last[job]
+	n.last[job] = results
+	sinks := n.sinks
+	n.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	events := Events(job, before, results, time.Now())
+	for _, e := range events {
+		n.logger.Printf("задание %s: %s", job, e)
+
+		for _, s := range sinks {
+			if len(s.events) > 0 && !slices.Contains(s.events, e.Type) {
+				continue
+			}
+			select {
+			case s.queue <- e:
+			default:
+				n.logger.Printf("уведомление %s: очередь получателя заполнена, событие пропущено", e)
+			}
+		}
+	}
+	return events
+}
+
+// Run отправляет события из очередей в получатели до отмены ctx.
+// Отправка, выполняемая в момент отмены, прерывается, а события,
+// оставшиеся в очередях, не отправляются
+func (n *Notifier) Run(ctx context.Context) {
+	n.mu.Lock()
+	sinks := n.sinks
+	n.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, s := range sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case e := <-s.queue:
+					if ctx.Err() != nil {
+						return
+					}
+					if err := s.sink.Notify(ctx, e); err != nil {
+						n.logger.Printf("уведомление %s: %s", e, err)
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,331 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/notify"
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+func results(notFound bool, open ...int) []scan.Results {
+	r := scan.Results{Host: "host1", NotFound: notFound}
+	for _, p := range []int{22, 80, 443} {
+		ps := scan.PortState{Port: p, Status: scan.StatusClosed}
+		for _, o := range open {
+			if o == p {
+				ps.Open, ps.Status = true, scan.StatusOpen
+			}
+		}
+		r.PortStates = append(r.PortStates, ps)
+	}
+	return []scan.Results{r}
+}
+
+func TestEvents(t *testing.T) {
+	testCases := []struct {
+		name     string
+		before   []scan.Results
+		after    []scan.Results
+		expected []string
+	}{
+		{"NoChange", results(false, 22), results(false, 22), []string{}},
+		{"Opened", results(false, 22), results(false, 22, 80), []string{"port-opened host1:80"}},
+		{"Closed", results(false, 22, 443), results(false, 22), []string{"port-closed host1:443"}},
+		{"Unresolvable", results(false, 22), []scan.Results{{Host: "host1", NotFound: true}},
+			[]string{"host-unresolvable host1:0"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			events := notify.Events("dmz", tc.before, tc.after, time.Now())
+
+			got := []string{}
+			for _, e := range events {
+				if e.Job != "dmz" {
+					t.Errorf("Ожидали задание %q, получили %q\n", "dmz", e.Job)
+				}
+				got = append(got, e.Type+" "+e.Host+":"+strconv.Itoa(e.Port))
+			}
+
+			if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("Ожидали события %q, получили %q\n", tc.expected, got)
+			}
+		})
+	}
+}
+
+// recorder - получатель, запоминающий события
+// recorder - получатель, запоминающий события. Если block
+// не nil, отправка ждёт его закрытия или отмены ctx
+type recorder struct {
+	block chan struct{}
+
+	mu     sync.Mutex
+	events []notify.Event
+}
+
+func (r *recorder) Notify(ctx context.Context, e notify.Event) error {
+	if r.block != nil {
+		select {
+		case <-r.block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+// wait ждёт n событий и возвращает полученные события
+func (r *recorder) wait(t *testing.T, n int) []notify.Event {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r.mu.Lock()
+		events := slices.Clone(r.events)
+		r.mu.Unlock()
+
+		if len(events) >= n || time.Now().After(deadline) {
+			return events
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// run запускает отправку уведомлений n до завершения теста
+func run(t *testing.T, n *notify.Notifier) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		n.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestNotifierHandle(t *testing.T) {
+	n := notify.New(io.Discard)
+
+	all, opened := &recorder{}, &recorder{}
+	if err := n.AddSink(all); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.AddSink(opened, notify.PortOpened); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.AddSink(&recorder{}, "port-changed"); !errors.Is(err, notify.ErrUnknownEvent) {
+		t.Errorf("Ожидали ошибку %q, получили %v\n", notify.ErrUnknownEvent, err)
+	}
+
+	run(t, n)
+
+	// Первые результаты задания только запоминаются
+	if events := n.Handle("dmz", results(false, 22)); len(events) != 0 {
+		t.Errorf("Не ожидали событий, получили %v\n", events)
+	}
+
+	n.Handle("dmz", results(false, 80))
+
+	// Результаты других заданий сравниваются отдельно
+	n.Seed("lan", results(false, 22, 80, 443))
+	n.Handle("lan", results(false, 22, 80, 443))
+
+	if events := all.wait(t, 2); len(events) != 2 || events[0].Type != notify.PortOpened || events[1].Type != notify.PortClosed {
+		t.Errorf("Ожидали события открытия и закрытия порта, получили %v\n", events)
+	}
+	if events := opened.wait(t, 1); len(events) != 1 || events[0].Port != 80 {
+		t.Errorf("Ожидали событие открытия порта 80, получили %v\n", events)
+	}
+}
+
+func TestNotifierSlowSink(t *testing.T) {
+	var log strings.Builder
+	n := notify.New(&log)
+
+	slow, fast := &recorder{block: make(chan struct{})}, &recorder{}
+	n.AddSink(slow)
+	n.AddSink(fast)
+	run(t, n)
+
+	// Переход всех портов из закрытых в открытые: событий больше,
+	// чем помещается в очередь получателя
+	before, after := scan.Results{Host: "host1"}, scan.Results{Host: "host1"}
+	for p := 1; p <= notify.QueueSize+10; p++ {
+		before.PortStates = append(before.PortStates, scan.PortState{Port: p, Status: scan.StatusClosed})
+		after.PortStates = append(after.PortStates, scan.PortState{Port: p, Open: true, Status: scan.StatusOpen})
+	}
+	n.Seed("dmz", []scan.Results{before})
+
+	// Handle не ждёт отправки в заблокированный получатель
+	done := make(chan []notify.Event)
+	go func() {
+		done <- n.Handle("dmz", []scan.Results{after})
+	}()
+	var events []notify.Event
+	select {
+	case events = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Handle ждёт отправки уведомлений")
+	}
+
+	// Другой получатель получает события, пока первый заблокирован
+	if got := fast.wait(t, notify.QueueSize); len(got) < notify.QueueSize {
+		t.Errorf("Ожидали не меньше %d событий у быстрого получателя, получили %d\n", notify.QueueSize, len(got))
+	}
+	if !strings.Contains(log.String(), "событие пропущено") {
+		t.Errorf("Ожидали сообщение о переполнении очереди, получили:\n%s\n", log.String())
+	}
+
+	// После разблокировки получатель получает события из очереди
+	close(slow.block)
+	if got := slow.wait(t, notify.QueueSize); len(got) < notify.QueueSize || len(got) == len(events) {
+		t.Errorf("Ожидали от %d до %d событий у медленного получателя, получили %d\n",
+			notify.QueueSize, len(events)-1, len(got))
+	}
+}
+
+func testEvent() notify.Event {
+	from, to := scan.StatusClosed, scan.StatusOpen
+	return notify.Event{
+		Type: notify.PortOpened,
+		Job:  "dmz",
+		Time: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Host: "host1",
+		Port: 8080,
+		From: &from,
+		To:   &to,
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	testCases := []struct {
+		name        string
+		codes       []int
+		retries     int
+		expCalls    int32
+		expectedErr bool
+	}{
+		{"OK", []int{http.StatusNoContent}, 2, 1, false},
+		{"RetryServerError", []int{http.StatusBadGateway, http.StatusOK}, 2, 2, false},
+		{"RetryTooManyRequests", []int{http.StatusTooManyRequests, http.StatusOK}, 1, 2, false},
+		{"RetriesExhausted", []int{500, 500, 500}, 2, 3, true},
+		{"NoRetryClientError", []int{http.StatusBadRequest, http.StatusOK}, 2, 1, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := calls.Add(1)
+
+				var e notify.Event
+				if err := json.NewDecoder(r.Body).Decode(&e); err != nil || e.Port != 8080 {
+					t.Errorf("Неверное тело запроса: %v, %v\n", e, err)
+				}
+				if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Неверный Content-Type: %q\n", ct)
+				}
+				w.WriteHeader(tc.codes[n-1])
+			}))
+			defer ts.Close()
+
+			w := &notify.Webhook{URL: ts.URL, Retries: tc.retries, Backoff: time.Millisecond}
+			err := w.Notify(context.Background(), testEvent())
+
+			if tc.expectedErr != (err != nil) {
+				t.Errorf("Ожидали ошибку: %t, получили: %v\n", tc.expectedErr, err)
+			}
+			if calls.Load() != tc.expCalls {
+				t.Errorf("Ожидали %d запросов, получили %d\n", tc.expCalls, calls.Load())
+			}
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "event")
+
+	c := &notify.Command{Shell: `cat > "` + out + `"; echo "$PSCAN_EVENT $PSCAN_HOST $PSCAN_PORT" >> "` + out + `"`}
+	if err := c.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Ожидали 2 строки, получили %q\n", lines)
+	}
+
+	var e notify.Event
+	if err := json.Unmarshal([]byte(lines[0]), &e); err != nil || e.Host != "host1" {
+		t.Errorf("Неверное событие на стандартном вводе: %q\n", lines[0])
+	}
+	if lines[1] != "port-opened host1 8080" {
+		t.Errorf("Ожидали %q, получили %q\n", "port-opened host1 8080", lines[1])
+	}
+
+	c = &notify.Command{Shell: "echo fail >&2; exit 3"}
+	err = c.Notify(context.Background(), testEvent())
+	if err == nil || !strings.Contains(err.Error(), "fail") {
+		t.Errorf("Ожидали ошибку с выводом команды, получили %v\n", err)
+	}
+}
+
+func TestMailDrop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mbox")
+	m := &notify.MailDrop{Path: path, To: "ops@example.com"}
+
+	for range 2 {
+		if err := m.Notify(context.Background(), testEvent()); err != nil {
+			t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbox := string(data)
+
+	if n := strings.Count(mbox, "\nFrom pscan@localhost ") + 1; !strings.HasPrefix(mbox, "From pscan@localhost Thu Jan  2 03:04:05 2025\n") || n != 2 {
+		t.Errorf("Ожидали 2 письма в mbox, получили:\n%s\n", mbox)
+	}
+
+	for _, exp := range []string{
+		"To: ops@example.com\n",
+		"Subject: =?utf-8?q?",
+		"host1:8080 открыт (было: closed)\n",
+		`"type": "port-opened"`,
+	} {
+		if !strings.Contains(mbox, exp) {
+			t.Errorf("Ожидали %q в письме:\n%s\n", exp, mbox)
+		}
+	}
+}
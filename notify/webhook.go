@@ -0,0 +1,81 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultBackoff - пауза между повторными отправками по умолчанию
+const DefaultBackoff = time.Second
+
+// Webhook отправляет событие в формате JSON методом POST на URL.
+// При сетевой ошибке или ответе 429 и 5xx отправка повторяется
+// до Retries раз с паузой Backoff
+type Webhook struct {
+	URL     string
+	Retries int
+	Backoff time.Duration
+	// Client - HTTP клиент, по умолчанию http.DefaultClient
+	Client *http.Client
+}
+
+// Notify отправляет событие e
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	backoff := w.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = w.post(ctx, client, body)
+		if err == nil || !retry || attempt >= w.Retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// post выполняет одну попытку отправки. retry сообщает,
+// имеет ли смысл повторить неудачную попытку
+func (w *Webhook) post(ctx context.Context, client *http.Client, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pScan")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("%s: %s", w.URL, resp.Status)
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
@@ -0,0 +1,225 @@
+// Package policy проверяет результаты сканирования на соответствие
+// ожидаемому состоянию портов, описанному в YAML файле политики:
+//
+//	groups:
+//	  - name: db
+//	    hosts: ["db*", "10.0.1.0/24"]
+//	    open: "5432"
+//	    only: true
+//	  - name: web
+//	    hosts: ["web1", "web2"]
+//	    open: "http,https"
+//	    closed: "22,3306"
+//
+// open - порты, которые должны быть открыты, closed - порты, которые
+// должны быть закрыты, only - кроме портов open не должно быть
+// открыто ни одного из просканированных портов
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+var ErrNoGroups = errors.New("в политике не описано ни одной группы")
+
+// Group - ожидаемое состояние портов группы хостов
+type Group struct {
+	Name string `yaml:"name"`
+	// Hosts - имена хостов, шаблоны имён ("db*") или подсети CIDR
+	Hosts  []string `yaml:"hosts"`
+	Open   string   `yaml:"open"`
+	Closed string   `yaml:"closed"`
+	Only   bool     `yaml:"only"`
+
+	open, closed []int
+}
+
+// Policy - набор групп хостов с ожидаемым состоянием портов
+type Policy struct {
+	Groups []Group `yaml:"groups"`
+}
+
+// Violation - нарушение политики
+type Violation struct {
+	Group    string
+	Host     string
+	Port     int
+	Expected string
+	Actual   string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: порт %d должен быть %s, а он %s (группа %s)",
+		v.Host, v.Port, v.Expected, v.Actual, v.Group)
+}
+
+// Report - результат проверки на соответствие политике
+type Report struct {
+	Violations []Violation
+	// Errors - ошибки сканирования, из-за которых проверка неполна:
+	// ненайденные хосты и порты в состоянии error
+	Errors []string
+}
+
+// Compliant сообщает, что нарушений и ошибок сканирования нет
+func (r Report) Compliant() bool {
+	return len(r.Violations) == 0 && len(r.Errors) == 0
+}
+
+// Load загружает политику из YAML файла
+func Load(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return p, nil
+}
+
+// Parse разбирает политику в формате YAML
+func Parse(data []byte) (*Policy, error) {
+	p := &Policy{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+
+	if len(p.Groups) == 0 {
+		return nil, ErrNoGroups
+	}
+
+	for i := range p.Groups {
+		g := &p.Groups[i]
+		if g.Name == "" {
+			g.Name = fmt.Sprintf("#%d", i+1)
+		}
+
+		var err error
+		if g.Open != "" {
+			if g.open, err = scan.ParsePorts(g.Open); err != nil {
+				return nil, fmt.Errorf("группа %s: open: %w", g.Name, err)
+			}
+		}
+		if g.Closed != "" {
+			if g.closed, err = scan.ParsePorts(g.Closed); err != nil {
+				return nil, fmt.Errorf("группа %s: closed: %w", g.Name, err)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// Ports возвращает все порты, упомянутые в политике. Их нужно
+// просканировать, чтобы проверка была полной
+func (p *Policy) Ports() []int {
+	var lists [][]int
+	for _, g := range p.Groups {
+		lists = append(lists, g.open, g.closed)
+	}
+	return scan.MergePorts(lists...)
+}
+
+// matches сообщает, относится ли хост host к группе
+func (g Group) matches(host string) bool {
+	for _, pattern := range g.Hosts {
+		if pattern == host {
+			return true
+		}
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+		if prefix, err := netip.ParsePrefix(pattern); err == nil {
+			if addr, err := netip.ParseAddr(host); err == nil && prefix.Contains(addr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Evaluate проверяет результаты сканирования на соответствие политике.
+// Если хост относится к нескольким группам, проверяются правила
+// каждой из них
+func (p *Policy) Evaluate(results []scan.Results) Report {
+	var rep Report
+
+	for _, r := range results {
+		if r.NotFound {
+			rep.Errors = append(rep.Errors, fmt.Sprintf("%s: хост не найден", r.Target()))
+			continue
+		}
+
+		states := make(map[int]scan.PortState, len(r.PortStates))
+		for _, ps := range r.PortStates {
+			states[ps.Port] = ps
+			if ps.Status == scan.StatusError {
+				rep.Errors = append(rep.Errors, fmt.Sprintf("%s: порт %d: %s", r.Target(), ps.Port, ps.Reason))
+			}
+		}
+
+		for _, g := range p.Groups {
+			if !g.matches(r.Host) && (r.IP == "" || !g.matches(r.IP)) {
+				continue
+			}
+			rep.Violations = append(rep.Violations, g.evaluate(r, states)...)
+		}
+	}
+
+	return rep
+}
+
+// evaluate проверяет один хост на соответствие правилам группы
+func (g Group) evaluate(r scan.Results, states map[int]scan.PortState) []Violation {
+	var violations []Violation
+
+	add := func(port int, expected, actual string) {
+		violations = append(violations, Violation{
+			Group:    g.Name,
+			Host:     r.Target(),
+			Port:     port,
+			Expected: expected,
+			Actual:   actual,
+		})
+	}
+
+	mustBeOpen := make(map[int]bool, len(g.open))
+	for _, port := range g.open {
+		mustBeOpen[port] = true
+
+		ps, ok := states[port]
+		switch {
+		case !ok:
+			add(port, "open", "не просканирован")
+		case ps.Status != scan.StatusOpen && ps.Status != scan.StatusError:
+			add(port, "open", ps.Status.String())
+		}
+	}
+
+	for _, port := range g.closed {
+		if ps, ok := states[port]; ok && ps.Status == scan.StatusOpen {
+			add(port, "closed", ps.Status.String())
+		}
+	}
+
+	if g.Only {
+		for _, ps := range r.PortStates {
+			if ps.Status == scan.StatusOpen && !mustBeOpen[ps.Port] && !slices.Contains(g.closed, ps.Port) {
+				add(ps.Port, "closed", ps.Status.String())
+			}
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,118 @@
+package policy_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"vegorov.ru/go-cli/pScan/policy"
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+const testPolicy = `
+groups:
+  - name: db
+    hosts: ["db*", "10.0.1.0/24"]
+    open: 5432
+    only: true
+  - name: web
+    hosts: [web1]
+    open: "http,https"
+    closed: "22"
+`
+
+func TestLoad(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(f, []byte(testPolicy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := policy.Load(f)
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	if len(p.Groups) != 2 || p.Groups[0].Name != "db" || !p.Groups[0].Only {
+		t.Errorf("Неверно разобрана политика: %+v\n", p.Groups)
+	}
+
+	expected := []int{5432, 80, 443, 22}
+	if !reflect.DeepEqual(p.Ports(), expected) {
+		t.Errorf("Ожидали порты %v, а получили %v\n", expected, p.Ports())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		data        string
+		expectedErr error
+	}{
+		{"NoGroups", "groups: []", policy.ErrNoGroups},
+		{"BadPorts", "groups: [{name: x, open: '70000'}]", scan.ErrInvalidPort},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := policy.Parse([]byte(tc.data)); !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Ожидали ошибку %q, а получили %v\n", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	p, err := policy.Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	open := func(port int) scan.PortState {
+		return scan.PortState{Port: port, Open: true, Status: scan.StatusOpen}
+	}
+	closed := func(port int) scan.PortState {
+		return scan.PortState{Port: port, Status: scan.StatusClosed}
+	}
+
+	results := []scan.Results{
+		// Соответствует политике
+		{Host: "db1", PortStates: []scan.PortState{open(5432), closed(22), closed(80)}},
+		// Лишний открытый порт
+		{Host: "10.0.1.5", PortStates: []scan.PortState{open(5432), open(22)}},
+		// Обязательный порт закрыт, запрещённый открыт
+		{Host: "web1", PortStates: []scan.PortState{open(80), closed(443), open(22)}},
+		// Не относится ни к одной группе
+		{Host: "other", PortStates: []scan.PortState{open(22)}},
+		// Ошибки сканирования
+		{Host: "db2", NotFound: true},
+		{Host: "db3", PortStates: []scan.PortState{
+			{Port: 5432, Status: scan.StatusError, Reason: "too many open files"},
+		}},
+	}
+
+	rep := p.Evaluate(results)
+
+	expected := []policy.Violation{
+		{Group: "db", Host: "10.0.1.5", Port: 22, Expected: "closed", Actual: "open"},
+		{Group: "web", Host: "web1", Port: 443, Expected: "open", Actual: "closed"},
+		{Group: "web", Host: "web1", Port: 22, Expected: "closed", Actual: "open"},
+	}
+
+	if !reflect.DeepEqual(rep.Violations, expected) {
+		t.Errorf("Ожидали нарушения:\n%+v\nа получили:\n%+v\n", expected, rep.Violations)
+	}
+
+	if len(rep.Errors) != 2 {
+		t.Errorf("Ожидали 2 ошибки сканирования, а получили %d: %v\n", len(rep.Errors), rep.Errors)
+	}
+
+	if rep.Compliant() {
+		t.Error("Ожидали несоответствие политике")
+	}
+
+	if !p.Evaluate(results[:1]).Compliant() {
+		t.Error("Ожидали соответствие политике")
+	}
+}
@@ -0,0 +1,64 @@
+package scan
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultBannerTimeout - время ожидания приветствия сервиса
+	// по умолчанию
+	DefaultBannerTimeout = 2 * time.Second
+
+	// MaxBannerSize - наибольший размер читаемого приветствия в байтах
+	MaxBannerSize = 1024
+)
+
+// readGreeting читает приветствие, которое сервис отправляет сразу
+// после установки соединения (SSH, SMTP, FTP и т.п.). Читается
+// не более MaxBannerSize байт за время timeout. Если сервис молчит,
+// возвращается пустой срез
+func readGreeting(conn net.Conn, timeout time.Duration) []byte {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil
+	}
+
+	buf := make([]byte, MaxBannerSize)
+	n, _ := conn.Read(buf)
+	return buf[:n]
+}
+
+// sanitizeBanner делает приветствие пригодным для вывода: обрезает
+// пробельные символы по краям и заменяет непечатаемые символы
+// и некорректные последовательности UTF-8 на \xNN. Переводы строк
+// и табуляции внутри приветствия сохраняются
+func sanitizeBanner(b []byte) string {
+	var sb strings.Builder
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			fmt.Fprintf(&sb, `\x%02x`, b[0])
+		case r == '\n' || r == '\t' || unicode.IsPrint(r):
+			sb.WriteRune(r)
+		case r == '\r':
+			// \r\n сводится к \n
+		default:
+			for _, c := range b[:size] {
+				fmt.Fprintf(&sb, `\x%02x`, c)
+			}
+		}
+		b = b[size:]
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// bannerLine возвращает приветствие одной строкой: переводы строк
+// и табуляции заменяются пробелами
+func bannerLine(banner string) string {
+	return strings.Join(strings.Fields(banner), " ")
+}
@@ -0,0 +1,81 @@
+package scan_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+// listenGreeting запускает сервер, отправляющий greeting каждому
+// клиенту. Пустое приветствие - сервер молчит
+func listenGreeting(t *testing.T, greeting string) int {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if greeting != "" {
+				conn.Write([]byte(greeting))
+			}
+			go func() {
+				// Соединение закрывается после того, как клиент
+				// прочитает приветствие и отключится
+				buf := make([]byte, 1)
+				conn.Read(buf)
+				conn.Close()
+			}()
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestRunContextBanner(t *testing.T) {
+	testCases := []struct {
+		name     string
+		greeting string
+		banner   bool
+		expected string
+	}{
+		{"SSH", "SSH-2.0-OpenSSH_9.6\r\n", true, "SSH-2.0-OpenSSH_9.6"},
+		{"MultiLine", "220-mail.example.com\r\n220 ESMTP\r\n", true, "220-mail.example.com\n220 ESMTP"},
+		{"Binary", "\x00\x01redis\xff\r\n", true, `\x00\x01redis\xff`},
+		{"Silent", "", true, ""},
+		{"Disabled", "SSH-2.0-OpenSSH_9.6\r\n", false, ""},
+	}
+
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			port := listenGreeting(t, tc.greeting)
+
+			opts := scan.Options{Banner: tc.banner, BannerTimeout: 200 * time.Millisecond}
+			res, err := scan.RunContext(context.Background(), hl, []int{port}, opts)
+			if err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+			}
+
+			ps := res[0].PortStates[0]
+			if ps.Status != scan.StatusOpen {
+				t.Fatalf("Ожидали порт %d в состоянии open, а получили %s\n", port, ps.Status)
+			}
+			if ps.Banner != tc.expected {
+				t.Errorf("Ожидали приветствие %q, а получили %q\n", tc.expected, ps.Banner)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package scan
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVColumns - колонки CSV вывода в порядке по умолчанию
+var CSVColumns = []string{"host", "ip", "port", "protocol", "state", "latency", "service", "timestamp", "banner", "product", "version",
+	"tls_version", "tls_cipher", "cert_subject", "cert_sans", "cert_issuer", "cert_not_after", "cert_flags"}
+
+var ErrUnknownColumn = errors.New("неизвестная колонка")
+
+// hostAddr возвращает адрес хоста: адрес сканирования, если он
+// известен, иначе сам хост или первый из разрешённых адресов
+func hostAddr(r Results) string {
+	if r.IP != "" {
+		return r.IP
+	}
+	if _, err := netip.ParseAddr(r.Host); err == nil {
+		return r.Host
+	}
+	if len(r.Addresses) > 0 {
+		return r.Addresses[0]
+	}
+	return ""
+}
+
+// csvValue возвращает значение колонки column для порта p хоста r.
+// Для ненайденного хоста или хоста без портов p равен nil
+func csvValue(column string, r Results, p *PortState) string {
+	switch column {
+	case "host":
+		return r.Host
+	case "ip":
+		return hostAddr(r)
+	case "timestamp":
+		if r.ScannedAt.IsZero() {
+			return ""
+		}
+		return r.ScannedAt.Format(time.RFC3339)
+	case "state":
+		if r.NotFound {
+			return "not_found"
+		}
+	}
+
+	if p == nil {
+		return ""
+	}
+
+	switch column {
+	case "port":
+		return strconv.Itoa(p.Port)
+	case "protocol":
+		return p.protocol()
+	case "state":
+		return p.Status.String()
+	case "latency":
+		return strconv.FormatFloat(float64(p.Latency)/float64(time.Millisecond), 'f', 3, 64)
+	case "service":
+		return p.serviceName()
+	case "banner":
+		return p.Banner
+	case "product":
+		return p.Product
+	case "version":
+		return p.Version
+	}
+
+	if p.TLS == nil {
+		return ""
+	}
+
+	switch column {
+	case "tls_version":
+		return p.TLS.Version
+	case "tls_cipher":
+		return p.TLS.CipherSuite
+	case "cert_subject":
+		return p.TLS.Subject
+	case "cert_sans":
+		return strings.Join(p.TLS.SANs, " ")
+	case "cert_issuer":
+		return p.TLS.Issuer
+	case "cert_not_after":
+		return p.TLS.NotAfter.Format(time.RFC3339)
+	case "cert_flags":
+		return strings.Join(p.TLS.Flags(), " ")
+	}
+	return ""
+}
+
+// WriteCSV записывает результаты сканирования в out по одной строке
+// на пару хост/порт, с заголовком из имён колонок. columns - набор
+// колонок из CSVColumns, при пустом значении выводятся все колонки.
+// comma - разделитель полей, например ',' для CSV или '\t' для TSV.
+// Ненайденный хост выводится отдельной строкой с состоянием not_found
+func WriteCSV(out io.Writer, results []Results, columns []string, comma rune) error {
+	if len(columns) == 0 {
+		columns = CSVColumns
+	}
+
+	known := make(map[string]bool, len(CSVColumns))
+	for _, c := range CSVColumns {
+		known[c] = true
+	}
+	for _, c := range columns {
+		if !known[c] {
+			return fmt.Errorf("%w: %q", ErrUnknownColumn, c)
+		}
+	}
+
+	w := csv.NewWriter(out)
+	w.Comma = comma
+
+	if err := w.Write(columns); err != nil {
+		return err
+	}
+
+	row := func(r Results, p *PortState) error {
+		record := make([]string, 0, len(columns))
+		for _, c := range columns {
+			record = append(record, csvValue(c, r, p))
+		}
+		return w.Write(record)
+	}
+
+	for _, r := range results {
+		if len(r.PortStates) == 0 {
+			if err := row(r, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for i := range r.PortStates {
+			if err := row(r, &r.PortStates[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,66 @@
+package scan_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+func TestWriteCSV(t *testing.T) {
+	testCases := []struct {
+		name        string
+		columns     []string
+		comma       rune
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:  "AllColumns",
+			comma: ',',
+			expected: "host,ip,port,protocol,state,latency,service,timestamp,banner,product,version," +
+				"tls_version,tls_cipher,cert_subject,cert_sans,cert_issuer,cert_not_after,cert_flags\n" +
+				"localhost,127.0.0.1,22,tcp,open,1.500,ssh,2025-01-02T03:04:05Z,SSH-2.0-OpenSSH_9.6,OpenSSH,9.6,,,,,,,\n" +
+				"localhost,127.0.0.1,25,tcp,closed,1.000,smtp,2025-01-02T03:04:05Z,,,,,,,,,,\n" +
+				"not-found-host,,,,not_found,,,2025-01-02T03:04:05Z,,,,,,,,,,\n",
+		},
+		{
+			name:    "SelectedColumnsTSV",
+			columns: []string{"state", "host", "port"},
+			comma:   '\t',
+			expected: "state\thost\tport\n" +
+				"open\tlocalhost\t22\n" +
+				"closed\tlocalhost\t25\n" +
+				"not_found\tnot-found-host\t\n",
+		},
+		{
+			name:        "UnknownColumn",
+			columns:     []string{"host", "owner"},
+			comma:       ',',
+			expectedErr: scan.ErrUnknownColumn,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := scan.WriteCSV(&out, testResults(), tc.columns, tc.comma)
+
+			if tc.expectedErr != nil {
+				if !errors.Is(err, tc.expectedErr) {
+					t.Fatalf("Ожидали ошибку %q, а получили %v\n", tc.expectedErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+			}
+
+			if out.String() != tc.expected {
+				t.Errorf("Ожидали вывод:\n%q, а получили:\n%q\n", tc.expected, out.String())
+			}
+		})
+	}
+}
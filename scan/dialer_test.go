@@ -0,0 +1,116 @@
+package scan_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/scan"
+	"vegorov.ru/go-cli/pScan/scan/scantest"
+)
+
+func TestRunContextDialer(t *testing.T) {
+	d := scantest.NewDialer()
+	d.Script("tcp", "192.0.2.1:22", scantest.Open("SSH-2.0-OpenSSH_9.6\r\n"))
+	d.Script("tcp", "192.0.2.1:80", scantest.Open(""))
+	d.Script("tcp", "192.0.2.1:443", scantest.Timeout())
+	d.Script("tcp", "192.0.2.1:8080", scantest.Timeout(), scantest.Open(""))
+	d.Script("tcp", "[2001:db8::1]:22", scantest.Open(""))
+
+	hl := &scan.HostsList{}
+	hl.Add("192.0.2.1")
+	hl.Add("2001:db8::1")
+
+	opts := scan.Options{Dialer: d, Retries: 1, Banner: true, BannerTimeout: 50 * time.Millisecond}
+	res, err := scan.RunContext(context.Background(), hl, []int{22, 80, 443, 8080, 25}, opts)
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	expected := []struct {
+		status scan.Status
+		banner string
+	}{
+		{scan.StatusOpen, "SSH-2.0-OpenSSH_9.6"},
+		{scan.StatusOpen, ""},
+		{scan.StatusFiltered, ""},
+		// Первая попытка не получила ответа, вторая - соединилась
+		{scan.StatusOpen, ""},
+		// Адрес без сценария - порт закрыт
+		{scan.StatusClosed, ""},
+	}
+	for i, exp := range expected {
+		ps := res[0].PortStates[i]
+		if ps.Status != exp.status || ps.Banner != exp.banner {
+			t.Errorf("Ожидали порт %d %s %q, а получили %s %q (%s)\n",
+				ps.Port, exp.status, exp.banner, ps.Status, ps.Banner, ps.Reason)
+		}
+	}
+
+	if ps := res[1].PortStates[0]; ps.Status != scan.StatusOpen {
+		t.Errorf("Ожидали открытый порт 22 на IPv6 адресе, а получили %s (%s)\n", ps.Status, ps.Reason)
+	}
+
+	dials := d.Dials()
+	for addr, n := range map[string]int{
+		"tcp 192.0.2.1:22":   1,
+		"tcp 192.0.2.1:443":  2,
+		"tcp 192.0.2.1:8080": 2,
+		"tcp 192.0.2.1:25":   2,
+	} {
+		if got := countOf(dials, addr); got != n {
+			t.Errorf("Ожидали %d соединений %s, а получили %d\n", n, addr, got)
+		}
+	}
+}
+
+func TestRunContextDialerUDP(t *testing.T) {
+	d := scantest.NewDialer()
+	d.Script("udp", "192.0.2.1:53", scantest.Open("\x12\x34\x81\x80"))
+	d.Script("udp", "192.0.2.1:514", scantest.Open(""))
+
+	hl := &scan.HostsList{}
+	hl.Add("192.0.2.1")
+
+	opts := scan.Options{Dialer: d, UDP: true, Timeout: 50 * time.Millisecond}
+	res, err := scan.RunContext(context.Background(), hl, []int{53, 514, 161}, opts)
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	expected := []scan.Status{scan.StatusOpen, scan.StatusOpenFiltered, scan.StatusClosed}
+	for i, exp := range expected {
+		if ps := res[0].PortStates[i]; ps.Status != exp {
+			t.Errorf("Ожидали порт %d/udp %s, а получили %s (%s)\n", ps.Port, exp, ps.Status, ps.Reason)
+		}
+	}
+	if s := res[0].PortStates[0].Service; s != "domain" {
+		t.Errorf("Ожидали сервис domain, а получили %q\n", s)
+	}
+}
+
+func TestRunContextDialerCanceled(t *testing.T) {
+	d := scantest.NewDialer()
+	d.Default = scantest.Outcome{Delay: time.Hour}
+
+	hl := &scan.HostsList{}
+	hl.Add("192.0.2.1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	opts := scan.Options{Dialer: d, Timeout: time.Hour}
+	res, err := scan.RunContext(ctx, hl, []int{22, 80}, opts)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Ожидали ошибку %q, а получили %v\n", context.DeadlineExceeded, err)
+	}
+	if len(res) != 1 || len(res[0].PortStates) != 0 {
+		t.Errorf("Ожидали хост без проверенных портов, а получили %+v\n", res)
+	}
+}
+
+// countOf возвращает количество элементов s, равных v
+func countOf(s []string, v string) int {
+	return len(slices.DeleteFunc(slices.Clone(s), func(e string) bool { return e != v }))
+}
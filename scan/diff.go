@@ -0,0 +1,136 @@
+package scan
+
+// PortChange - изменение состояния или сервиса порта между
+// двумя сканированиями
+type PortChange struct {
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	Protocol   string `json:"protocol,omitempty"`
+	From       Status `json:"from"`
+	To         Status `json:"to"`
+	OldService string `json:"old_service,omitempty"`
+	NewService string `json:"new_service,omitempty"`
+}
+
+// Diff - различия между двумя сканированиями
+type Diff struct {
+	// Opened - порты, открытые во втором сканировании, но не в первом
+	Opened []PortChange `json:"opened"`
+	// Closed - порты, открытые в первом сканировании, но не во втором
+	Closed []PortChange `json:"closed"`
+	// ServiceChanged - открытые порты, на которых сменился сервис.
+	// Сервисы сравниваются, только если в обоих сканированиях они
+	// определены одинаково: пробами или по таблице сервисов
+	ServiceChanged []PortChange `json:"service_changed"`
+	// Failed - порты, открытые в первом сканировании, проверка
+	// которых во втором не удалась из-за локальной ошибки. Это
+	// не изменение состояния порта, поэтому Empty их не учитывает
+	Failed []PortChange `json:"failed"`
+	// Appeared и Disappeared - хосты, появившиеся во втором
+	// сканировании и исчезнувшие из него
+	Appeared    []string `json:"appeared"`
+	Disappeared []string `json:"disappeared"`
+	// Resolvable и Unresolvable - хосты, имя которых стало
+	// разрешаться и перестало разрешаться
+	Resolvable   []string `json:"resolvable"`
+	Unresolvable []string `json:"unresolvable"`
+}
+
+// Empty сообщает, что различий между сканированиями нет.
+// Порты из Failed различиями не считаются
+func (d Diff) Empty() bool {
+	return len(d.Opened) == 0 && len(d.Closed) == 0 && len(d.ServiceChanged) == 0 &&
+		len(d.Appeared) == 0 && len(d.Disappeared) == 0 &&
+		len(d.Resolvable) == 0 && len(d.Unresolvable) == 0
+}
+
+// Compare сравнивает результаты сканирования before и after.
+// Хосты сопоставляются по имени и адресу сканирования (Results.Target),
+// порты - по номеру и протоколу. Порт, который не сканировался
+// в before, но открыт в after, считается открывшимся; порт,
+// который не сканировался в after, не считается закрывшимся
+func Compare(before, after []Results) Diff {
+	d := Diff{
+		Opened:         []PortChange{},
+		Closed:         []PortChange{},
+		ServiceChanged: []PortChange{},
+		Failed:         []PortChange{},
+		Appeared:       []string{},
+		Disappeared:    []string{},
+		Resolvable:     []string{},
+		Unresolvable:   []string{},
+	}
+
+	prev := make(map[string]Results, len(before))
+	for _, r := range before {
+		prev[r.Target()] = r
+	}
+
+	seen := make(map[string]bool, len(after))
+	for _, cur := range after {
+		seen[cur.Target()] = true
+
+		old, ok := prev[cur.Target()]
+		switch {
+		case !ok:
+			d.Appeared = append(d.Appeared, cur.Target())
+		case old.NotFound && !cur.NotFound:
+			d.Resolvable = append(d.Resolvable, cur.Target())
+		case !old.NotFound && cur.NotFound:
+			d.Unresolvable = append(d.Unresolvable, cur.Target())
+		}
+
+		comparePorts(&d, cur.Target(), old.PortStates, cur.PortStates)
+	}
+
+	for _, r := range before {
+		if !seen[r.Target()] {
+			d.Disappeared = append(d.Disappeared, r.Target())
+		}
+	}
+
+	return d
+}
+
+// comparePorts добавляет в d изменения портов хоста host
+func comparePorts(d *Diff, host string, before, after []PortState) {
+	// TCP и UDP порты с одним номером - разные порты
+	type portKey struct {
+		port     int
+		protocol string
+	}
+
+	prev := make(map[portKey]PortState, len(before))
+	for _, p := range before {
+		prev[portKey{p.Port, p.protocol()}] = p
+	}
+
+	for _, cur := range after {
+		old, ok := prev[portKey{cur.Port, cur.protocol()}]
+		if !ok {
+			// Порт раньше не сканировался: его состояние неизвестно
+			old = PortState{Port: cur.Port, Status: StatusClosed}
+		}
+
+		change := PortChange{
+			Host:       host,
+			Port:       cur.Port,
+			Protocol:   cur.Protocol,
+			From:       old.Status,
+			To:         cur.Status,
+			OldService: old.Service,
+			NewService: cur.Service,
+		}
+
+		switch {
+		case old.Status != StatusOpen && cur.Status == StatusOpen:
+			d.Opened = append(d.Opened, change)
+		case ok && old.Status == StatusOpen && cur.Status == StatusError:
+			d.Failed = append(d.Failed, change)
+		case ok && old.Status == StatusOpen && cur.Status != StatusOpen:
+			d.Closed = append(d.Closed, change)
+		case ok && old.Status == StatusOpen && old.probed() == cur.probed() && old.Service != cur.Service:
+			d.ServiceChanged = append(d.ServiceChanged, change)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package scan_test
+
+import (
+	"reflect"
+	"testing"
+
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+func TestCompare(t *testing.T) {
+	before := []scan.Results{
+		{
+			Host: "host1",
+			PortStates: []scan.PortState{
+				{Port: 22, Status: scan.StatusOpen, Service: "ssh"},
+				{Port: 80, Status: scan.StatusClosed},
+				{Port: 443, Status: scan.StatusOpen, Service: "https"},
+				{Port: 8080, Status: scan.StatusOpen, Service: "http-alt"},
+				{Port: 25, Status: scan.StatusOpen, Service: "smtp"},
+				{Port: 3000, Status: scan.StatusOpen, Service: "ppp"},
+				{Port: 53, Protocol: "udp", Status: scan.StatusClosed},
+			},
+		},
+		{Host: "host2", NotFound: true},
+		{Host: "host3"},
+		{Host: "host4"},
+	}
+
+	after := []scan.Results{
+		{
+			Host: "host1",
+			PortStates: []scan.PortState{
+				{Port: 22, Status: scan.StatusOpen, Service: "ssh"},
+				{Port: 80, Status: scan.StatusOpen, Service: "http"},
+				{Port: 443, Status: scan.StatusFiltered},
+				{Port: 8080, Status: scan.StatusOpen, Service: "http"},
+				{Port: 9000, Status: scan.StatusOpen},
+				{Port: 25, Status: scan.StatusError, Reason: "too many open files"},
+				// Сервис определён пробами, а раньше взят из таблицы
+				{Port: 3000, Status: scan.StatusOpen, Service: "http", Product: "Grafana"},
+				{Port: 53, Protocol: "udp", Status: scan.StatusOpen, Service: "domain"},
+			},
+		},
+		{Host: "host2"},
+		{Host: "host3", NotFound: true},
+		{Host: "host5"},
+	}
+
+	d := scan.Compare(before, after)
+
+	expected := scan.Diff{
+		Opened: []scan.PortChange{
+			{Host: "host1", Port: 80, From: scan.StatusClosed, To: scan.StatusOpen, NewService: "http"},
+			{Host: "host1", Port: 9000, From: scan.StatusClosed, To: scan.StatusOpen},
+			{Host: "host1", Port: 53, Protocol: "udp", From: scan.StatusClosed, To: scan.StatusOpen, NewService: "domain"},
+		},
+		Closed: []scan.PortChange{
+			{Host: "host1", Port: 443, From: scan.StatusOpen, To: scan.StatusFiltered, OldService: "https"},
+		},
+		ServiceChanged: []scan.PortChange{
+			{Host: "host1", Port: 8080, From: scan.StatusOpen, To: scan.StatusOpen, OldService: "http-alt", NewService: "http"},
+		},
+		Failed: []scan.PortChange{
+			{Host: "host1", Port: 25, From: scan.StatusOpen, To: scan.StatusError, OldService: "smtp"},
+		},
+		Appeared:     []string{"host5"},
+		Disappeared:  []string{"host4"},
+		Resolvable:   []string{"host2"},
+		Unresolvable: []string{"host3"},
+	}
+
+	if !reflect.DeepEqual(d, expected) {
+		t.Errorf("Ожидали изменения:\n%+v\nа получили:\n%+v\n", expected, d)
+	}
+
+	if d.Empty() {
+		t.Error("Ожидали, что изменения есть")
+	}
+
+	if !scan.Compare(before, before).Empty() {
+		t.Error("Ожидали отсутствие изменений при сравнении с собой")
+	}
+}
+
+func TestCompareAddresses(t *testing.T) {
+	before := []scan.Results{
+		{Host: "host1", IP: "192.0.2.1", PortStates: []scan.PortState{{Port: 22, Status: scan.StatusOpen}}},
+		{Host: "host1", IP: "2001:db8::1", PortStates: []scan.PortState{{Port: 22, Status: scan.StatusClosed}}},
+	}
+	after := []scan.Results{
+		{Host: "host1", IP: "192.0.2.1", PortStates: []scan.PortState{{Port: 22, Status: scan.StatusOpen}}},
+		{Host: "host1", IP: "2001:db8::1", PortStates: []scan.PortState{{Port: 22, Status: scan.StatusOpen}}},
+	}
+
+	d := scan.Compare(before, after)
+
+	expected := []scan.PortChange{
+		{Host: "host1 (2001:db8::1)", Port: 22, From: scan.StatusClosed, To: scan.StatusOpen},
+	}
+	if !reflect.DeepEqual(d.Opened, expected) {
+		t.Errorf("Ожидали открытые порты:\n%+v\nа получили:\n%+v\n", expected, d.Opened)
+	}
+	if len(d.Appeared) != 0 || len(d.Disappeared) != 0 {
+		t.Errorf("Не ожидали появившихся и исчезнувших хостов, а получили %v и %v\n", d.Appeared, d.Disappeared)
+	}
+}
@@ -30,8 +30,21 @@ func (hl *HostsList) search(host string) (bool, int) {
 	return false, -1
 }
 
-// Add добавляет хост в список
+// Add добавляет хост в список. Вместо отдельного хоста можно
+// добавить подсеть CIDR или диапазон адресов - они хранятся
+// в компактном виде и раскрываются только при сканировании.
+// Пустые записи и записи с пробелами или управляющими символами
+// не добавляются: каждая запись хранится отдельной строкой файла
 func (hl *HostsList) Add(host string) error {
+	if err := validateHostEntry(host); err != nil {
+		return err
+	}
+	e, err := parseHostEntry(host)
+	if err != nil {
+		return err
+	}
+	host = e.String()
+
 	if found, _ := hl.search(host); found {
 		return fmt.Errorf("%w: %s", ErrExists, host)
 	}
@@ -39,7 +52,12 @@ func (hl *HostsList) Add(host string) error {
 	return nil
 }
 
+// Remove удаляет хост, подсеть или диапазон из списка
 func (hl *HostsList) Remove(host string) error {
+	if e, err := parseHostEntry(host); err == nil {
+		host = e.String()
+	}
+
 	if found, i := hl.search(host); found {
 		hl.Hosts = slices.Delete(hl.Hosts, i, i+1)
 		return nil
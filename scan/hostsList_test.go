@@ -17,6 +17,10 @@ func TestAdd(t *testing.T) {
 	}{
 		{"AddNew", "host2", 2, nil},
 		{"AddExisting", "host1", 1, scan.ErrExists},
+		{"AddEmpty", "", 1, scan.ErrInvalidHost},
+		{"AddSpace", "host 2", 1, scan.ErrInvalidHost},
+		{"AddNewline", "host2\nhost3", 1, scan.ErrInvalidHost},
+		{"AddControl", "host2\x00", 1, scan.ErrInvalidHost},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,213 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// SchemaVersion - версия схемы JSON вывода результатов. Увеличивается
+// при любом несовместимом изменении полей; добавление новых
+// необязательных полей версию не меняет
+const SchemaVersion = 1
+
+// jsonReport - результаты сканирования в формате JSON
+type jsonReport struct {
+	SchemaVersion int        `json:"schema_version"`
+	Hosts         []jsonHost `json:"hosts"`
+}
+
+// jsonHost - результаты сканирования одного хоста. В формате NDJSON
+// каждая строка - отдельный jsonHost с заполненной версией схемы
+type jsonHost struct {
+	SchemaVersion int        `json:"schema_version,omitempty"`
+	Host          string     `json:"host"`
+	Addresses     []string   `json:"addresses"`
+	IP            string     `json:"ip,omitempty"`
+	NotFound      bool       `json:"not_found"`
+	ScannedAt     time.Time  `json:"scanned_at"`
+	Ports         []jsonPort `json:"ports"`
+}
+
+// jsonPort - состояние одного порта
+type jsonPort struct {
+	Port      int      `json:"port"`
+	Protocol  string   `json:"protocol,omitempty"`
+	State     string   `json:"state"`
+	Reason    string   `json:"reason,omitempty"`
+	LatencyMS float64  `json:"latency_ms"`
+	Service   string   `json:"service,omitempty"`
+	Banner    string   `json:"banner,omitempty"`
+	Product   string   `json:"product,omitempty"`
+	Version   string   `json:"version,omitempty"`
+	TLS       *TLSInfo `json:"tls,omitempty"`
+}
+
+// newJSONHost преобразует результаты сканирования хоста в JSON представление
+func newJSONHost(r Results) jsonHost {
+	h := jsonHost{
+		Host:      r.Host,
+		Addresses: r.Addresses,
+		IP:        r.IP,
+		NotFound:  r.NotFound,
+		ScannedAt: r.ScannedAt,
+		Ports:     make([]jsonPort, 0, len(r.PortStates)),
+	}
+	if h.Addresses == nil {
+		h.Addresses = []string{}
+	}
+
+	for _, p := range r.PortStates {
+		h.Ports = append(h.Ports, jsonPort{
+			Port:      p.Port,
+			Protocol:  p.Protocol,
+			State:     p.Status.String(),
+			Reason:    p.Reason,
+			LatencyMS: float64(p.Latency) / float64(time.Millisecond),
+			Service:   p.Service,
+			Banner:    p.Banner,
+			Product:   p.Product,
+			Version:   p.Version,
+			TLS:       p.TLS,
+		})
+	}
+	return h
+}
+
+// results преобразует JSON представление обратно в результаты сканирования
+func (h jsonHost) results() (Results, error) {
+	r := Results{
+		Host:      h.Host,
+		Addresses: h.Addresses,
+		IP:        h.IP,
+		NotFound:  h.NotFound,
+		ScannedAt: h.ScannedAt,
+	}
+
+	for _, jp := range h.Ports {
+		status, err := ParseStatus(jp.State)
+		if err != nil {
+			return Results{}, err
+		}
+		r.PortStates = append(r.PortStates, PortState{
+			Port:     jp.Port,
+			Protocol: jp.Protocol,
+			Open:     status == StatusOpen,
+			Status:   status,
+			Reason:   jp.Reason,
+			Latency:  time.Duration(jp.LatencyMS * float64(time.Millisecond)),
+			Service:  jp.Service,
+			Banner:   jp.Banner,
+			Product:  jp.Product,
+			Version:  jp.Version,
+			TLS:      jp.TLS,
+		})
+	}
+	return r, nil
+}
+
+// MarshalJSON кодирует результаты сканирования хоста
+// по схеме версии SchemaVersion
+func (r Results) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newJSONHost(r))
+}
+
+// UnmarshalJSON декодирует результаты сканирования хоста,
+// закодированные MarshalJSON
+func (r *Results) UnmarshalJSON(data []byte) error {
+	var h jsonHost
+	if err := json.Unmarshal(data, &h); err != nil {
+		return err
+	}
+
+	res, err := h.results()
+	if err != nil {
+		return err
+	}
+	*r = res
+	return nil
+}
+
+// WriteJSON записывает результаты сканирования в out одним JSON документом
+func WriteJSON(out io.Writer, results []Results) error {
+	report := jsonReport{
+		SchemaVersion: SchemaVersion,
+		Hosts:         make([]jsonHost, 0, len(results)),
+	}
+	for _, r := range results {
+		report.Hosts = append(report.Hosts, newJSONHost(r))
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// WriteNDJSON записывает результаты сканирования в out
+// по одному JSON объекту на хост в строке
+func WriteNDJSON(out io.Writer, results []Results) error {
+	enc := json.NewEncoder(out)
+	for _, r := range results {
+		h := newJSONHost(r)
+		h.SchemaVersion = SchemaVersion
+		if err := enc.Encode(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var ErrSchemaVersion = errors.New("неподдерживаемая версия схемы JSON")
+
+// ReadJSON читает результаты сканирования, записанные WriteJSON
+// или WriteNDJSON
+func ReadJSON(in io.Reader) ([]Results, error) {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+
+	// Документ WriteJSON содержит список хостов в поле hosts
+	var report struct {
+		SchemaVersion int       `json:"schema_version"`
+		Hosts         []Results `json:"hosts"`
+	}
+	if err := json.Unmarshal(data, &report); err == nil && report.Hosts != nil {
+		if report.SchemaVersion != SchemaVersion {
+			return nil, fmt.Errorf("%w: %d", ErrSchemaVersion, report.SchemaVersion)
+		}
+		return report.Hosts, nil
+	}
+
+	// Иначе считаем, что это NDJSON: по одному хосту в строке
+	var results []Results
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var v struct {
+			SchemaVersion int `json:"schema_version"`
+		}
+		if err := json.Unmarshal(line, &v); err != nil {
+			return nil, err
+		}
+		if v.SchemaVersion != SchemaVersion {
+			return nil, fmt.Errorf("%w: %d", ErrSchemaVersion, v.SchemaVersion)
+		}
+
+		var r Results
+		if err := json.Unmarshal(line, &r); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, scanner.Err()
+}
@@ -0,0 +1,188 @@
+package scan_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+// testResults возвращает результаты сканирования для тестов вывода
+func testResults() []scan.Results {
+	ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []scan.Results{
+		{
+			Host:      "localhost",
+			Addresses: []string{"127.0.0.1"},
+			ScannedAt: ts,
+			PortStates: []scan.PortState{
+				{Port: 22, Open: true, Status: scan.StatusOpen, Latency: 1500 * time.Microsecond, Banner: "SSH-2.0-OpenSSH_9.6", Product: "OpenSSH", Version: "9.6"},
+				{Port: 25, Status: scan.StatusClosed, Reason: "connection refused", Latency: time.Millisecond},
+			},
+		},
+		{
+			Host:      "not-found-host",
+			NotFound:  true,
+			ScannedAt: ts,
+		},
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := scan.WriteJSON(&out, testResults()); err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	var report struct {
+		SchemaVersion int `json:"schema_version"`
+		Hosts         []struct {
+			Host      string   `json:"host"`
+			Addresses []string `json:"addresses"`
+			NotFound  bool     `json:"not_found"`
+			ScannedAt string   `json:"scanned_at"`
+			Ports     []struct {
+				Port      int     `json:"port"`
+				State     string  `json:"state"`
+				Reason    string  `json:"reason"`
+				LatencyMS float64 `json:"latency_ms"`
+			} `json:"ports"`
+		} `json:"hosts"`
+	}
+
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("Ошибка разбора JSON: %q\n%s", err, out.String())
+	}
+
+	if report.SchemaVersion != scan.SchemaVersion {
+		t.Errorf("Ожидали версию схемы %d, а получили %d\n", scan.SchemaVersion, report.SchemaVersion)
+	}
+
+	if len(report.Hosts) != 2 {
+		t.Fatalf("Ожидали 2 хоста, а получили %d\n", len(report.Hosts))
+	}
+
+	h := report.Hosts[0]
+	if h.Host != "localhost" || h.NotFound || len(h.Addresses) != 1 || h.ScannedAt != "2025-01-02T03:04:05Z" {
+		t.Errorf("Неверные данные хоста: %+v\n", h)
+	}
+
+	if len(h.Ports) != 2 {
+		t.Fatalf("Ожидали 2 порта, а получили %d\n", len(h.Ports))
+	}
+
+	if h.Ports[0].Port != 22 || h.Ports[0].State != "open" || h.Ports[0].LatencyMS != 1.5 {
+		t.Errorf("Неверные данные порта: %+v\n", h.Ports[0])
+	}
+
+	if h.Ports[1].State != "closed" || h.Ports[1].Reason != "connection refused" {
+		t.Errorf("Неверные данные порта: %+v\n", h.Ports[1])
+	}
+
+	if !report.Hosts[1].NotFound || report.Hosts[1].Addresses == nil || report.Hosts[1].Ports == nil {
+		t.Errorf("Неверные данные ненайденного хоста: %+v\n", report.Hosts[1])
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := scan.WriteNDJSON(&out, testResults()); err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	hosts := []string{}
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var h struct {
+			SchemaVersion int    `json:"schema_version"`
+			Host          string `json:"host"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &h); err != nil {
+			t.Fatalf("Ошибка разбора строки NDJSON: %q\n", err)
+		}
+		if h.SchemaVersion != scan.SchemaVersion {
+			t.Errorf("Ожидали версию схемы %d, а получили %d\n", scan.SchemaVersion, h.SchemaVersion)
+		}
+		hosts = append(hosts, h.Host)
+	}
+
+	if len(hosts) != 2 || hosts[0] != "localhost" || hosts[1] != "not-found-host" {
+		t.Errorf("Ожидали строки для localhost и not-found-host, а получили %v\n", hosts)
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	writers := []struct {
+		name  string
+		write func(io.Writer, []scan.Results) error
+	}{
+		{"JSON", scan.WriteJSON},
+		{"NDJSON", scan.WriteNDJSON},
+	}
+
+	for _, w := range writers {
+		t.Run(w.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := w.write(&buf, testResults()); err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := scan.ReadJSON(&buf)
+			if err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+			}
+
+			expected := testResults()
+			if len(res) != len(expected) {
+				t.Fatalf("Ожидали %d хостов, а получили %d\n", len(expected), len(res))
+			}
+
+			for i := range expected {
+				if res[i].Host != expected[i].Host || res[i].IP != expected[i].IP || res[i].NotFound != expected[i].NotFound ||
+					!res[i].ScannedAt.Equal(expected[i].ScannedAt) {
+					t.Errorf("Ожидали хост %+v, а получили %+v\n", expected[i], res[i])
+				}
+
+				if !reflect.DeepEqual(res[i].PortStates, expected[i].PortStates) {
+					t.Errorf("Ожидали порты %+v, а получили %+v\n", expected[i].PortStates, res[i].PortStates)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONAddress(t *testing.T) {
+	results := []scan.Results{
+		{Host: "host1", Addresses: []string{"192.0.2.1", "2001:db8::1"}, IP: "2001:db8::1"},
+	}
+
+	var buf bytes.Buffer
+	if err := scan.WriteNDJSON(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"ip":"2001:db8::1"`) {
+		t.Errorf("Ожидали адрес сканирования в выводе, а получили %s\n", buf.String())
+	}
+
+	res, err := scan.ReadJSON(&buf)
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+	if len(res) != 1 || res[0].IP != "2001:db8::1" {
+		t.Errorf("Ожидали адрес сканирования 2001:db8::1, а получили %+v\n", res)
+	}
+}
+
+func TestReadJSONSchemaVersion(t *testing.T) {
+	in := strings.NewReader(`{"schema_version": 99, "hosts": []}`)
+	if _, err := scan.ReadJSON(in); !errors.Is(err, scan.ErrSchemaVersion) {
+		t.Errorf("Ожидали ошибку %q, а получили %v\n", scan.ErrSchemaVersion, err)
+	}
+}
@@ -0,0 +1,123 @@
+package scan
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// rateLimiter равномерно распределяет соединения во времени:
+// не более rate соединений в секунду, без накопления запаса
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter возвращает ограничитель на rate соединений
+// в секунду. При rate <= 0 возвращается nil - без ограничения
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// wait ожидает очереди на соединение или отмены ctx
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleep(ctx, time.Until(slot))
+}
+
+// sleep ожидает d или отмены ctx
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttle - ограничения скорости сканирования, общие для всех
+// горутин одного запуска RunContext
+type throttle struct {
+	rate   *rateLimiter
+	jitter time.Duration
+	// hosts - семафоры одновременных проверок по индексам хостов,
+	// nil - без ограничения
+	hosts []chan struct{}
+}
+
+// newThrottle возвращает ограничения для n хостов с параметрами opts
+func newThrottle(opts Options, n int) *throttle {
+	t := &throttle{
+		rate:   newRateLimiter(opts.Rate),
+		jitter: opts.Jitter,
+	}
+
+	if opts.HostConcurrency > 0 {
+		t.hosts = make([]chan struct{}, n)
+		for i := range t.hosts {
+			t.hosts[i] = make(chan struct{}, opts.HostConcurrency)
+		}
+	}
+	return t
+}
+
+// beforeDial ожидает, пока можно будет открыть следующее соединение:
+// очереди ограничителя скорости и случайной паузы до Jitter
+func (t *throttle) beforeDial(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	if t.jitter > 0 {
+		if err := sleep(ctx, rand.N(t.jitter)); err != nil {
+			return err
+		}
+	}
+	return t.rate.wait(ctx)
+}
+
+// acquireHost занимает место для проверки порта хоста с индексом h
+func (t *throttle) acquireHost(ctx context.Context, h int) error {
+	if t == nil || t.hosts == nil {
+		return nil
+	}
+
+	select {
+	case t.hosts[h] <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// releaseHost освобождает место, занятое acquireHost
+func (t *throttle) releaseHost(h int) {
+	if t == nil || t.hosts == nil {
+		return
+	}
+	<-t.hosts[h]
+}
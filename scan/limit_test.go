@@ -0,0 +1,149 @@
+package scan_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/scan"
+	"vegorov.ru/go-cli/pScan/scan/scantest"
+)
+
+// listenSilent запускает n серверов, которые принимают соединения
+// и молчат, пока клиент их не закроет. Возвращает их порты
+func listenSilent(t *testing.T, n int) []int {
+	t.Helper()
+
+	ports := make([]int, 0, n)
+	for range n {
+		ports = append(ports, listenGreeting(t, ""))
+	}
+	return ports
+}
+
+func TestRunContextHostConcurrency(t *testing.T) {
+	const bannerTimeout = 200 * time.Millisecond
+
+	testCases := []struct {
+		name        string
+		concurrency int
+		minElapsed  time.Duration
+		maxElapsed  time.Duration
+	}{
+		// 6 портов по 2 одновременно - 3 очереди ожидания приветствия
+		{"Limited", 2, 3 * bannerTimeout, time.Minute},
+		{"Unlimited", 0, bannerTimeout, 2 * bannerTimeout},
+	}
+
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ports := listenSilent(t, 6)
+
+			// Чтение приветствия удерживает соединение на BannerTimeout
+			opts := scan.Options{
+				Banner:          true,
+				BannerTimeout:   bannerTimeout,
+				HostConcurrency: tc.concurrency,
+			}
+
+			start := time.Now()
+			res, err := scan.RunContext(context.Background(), hl, ports, opts)
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+			}
+
+			for _, ps := range res[0].PortStates {
+				if ps.Status != scan.StatusOpen {
+					t.Errorf("Ожидали порт %d в состоянии open, а получили %s\n", ps.Port, ps.Status)
+				}
+			}
+
+			if elapsed < tc.minElapsed || elapsed > tc.maxElapsed {
+				t.Errorf("Ожидали сканирование за %s-%s, а получили %s\n", tc.minElapsed, tc.maxElapsed, elapsed)
+			}
+		})
+	}
+}
+
+func TestRunContextHostConcurrencyAddresses(t *testing.T) {
+	const delay = 100 * time.Millisecond
+
+	r := scantest.NewResolver()
+	r.Add("host1", "192.0.2.1", "2001:db8::1")
+
+	// Каждое соединение устанавливается за delay
+	d := scantest.NewDialer()
+	d.Default = scantest.Outcome{Delay: delay}
+
+	hl := &scan.HostsList{}
+	hl.Add("host1")
+
+	// Ограничение общее для обоих адресов хоста: 4 соединения
+	// выполняются по одному
+	opts := scan.Options{Dialer: d, Resolver: r, IPVersion: scan.IPBoth, HostConcurrency: 1}
+	start := time.Now()
+	res, err := scan.RunContext(context.Background(), hl, []int{22, 80}, opts)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("Ожидали результаты для 2 адресов, а получили %d\n", len(res))
+	}
+
+	if elapsed < 4*delay {
+		t.Errorf("Ожидали сканирование не быстрее %s, а получили %s\n", 4*delay, elapsed)
+	}
+}
+
+func TestRunContextRate(t *testing.T) {
+	ports := listenSilent(t, 5)
+
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+
+	// 5 соединений со скоростью 20 в секунду занимают не менее
+	// 4 интервалов по 50мс
+	opts := scan.Options{Rate: 20, Jitter: time.Millisecond}
+
+	start := time.Now()
+	res, err := scan.RunContext(context.Background(), hl, ports, opts)
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Ожидали сканирование не быстрее 200мс, а получили %s\n", elapsed)
+	}
+	if len(res[0].PortStates) != len(ports) {
+		t.Errorf("Ожидали %d портов, а получили %d\n", len(ports), len(res[0].PortStates))
+	}
+}
+
+func TestRunContextRateCanceled(t *testing.T) {
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// Очередь на 100 соединений со скоростью 1 в секунду
+	// прерывается вместе с ctx
+	ports := make([]int, 0, 100)
+	for p := 40000; p < 40100; p++ {
+		ports = append(ports, p)
+	}
+
+	start := time.Now()
+	_, err := scan.RunContext(ctx, hl, ports, scan.Options{Rate: 1})
+	if err == nil {
+		t.Fatal("Ожидали ошибку отмены")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Сканирование не прервалось: %s\n", elapsed)
+	}
+}
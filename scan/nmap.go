@@ -0,0 +1,390 @@
+package scan
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nmapXMLVersion - версия формата XML вывода nmap, которой
+// соответствует WriteNmapXML
+const nmapXMLVersion = "1.05"
+
+// Структуры ниже описывают подмножество элементов nmap.dtd,
+// достаточное для вывода результатов сканирования TCP connect
+
+type nmapScanInfo struct {
+	Type        string `xml:"type,attr"`
+	Protocol    string `xml:"protocol,attr"`
+	NumServices int    `xml:"numservices,attr"`
+	Services    string `xml:"services,attr"`
+}
+
+type nmapLevel struct {
+	Level int `xml:"level,attr"`
+}
+
+type nmapHost struct {
+	XMLName   xml.Name      `xml:"host"`
+	StartTime int64         `xml:"starttime,attr,omitempty"`
+	Status    nmapStatus    `xml:"status"`
+	Address   nmapAddress   `xml:"address"`
+	Hostnames nmapHostnames `xml:"hostnames"`
+	Ports     nmapPorts     `xml:"ports"`
+}
+
+type nmapStatus struct {
+	State     string `xml:"state,attr"`
+	Reason    string `xml:"reason,attr"`
+	ReasonTTL int    `xml:"reason_ttl,attr"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+}
+
+type nmapHostnames struct {
+	Hostnames []nmapHostname `xml:"hostname"`
+}
+
+type nmapHostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type nmapPorts struct {
+	Ports []nmapPort `xml:"port"`
+}
+
+type nmapPort struct {
+	Protocol string       `xml:"protocol,attr"`
+	PortID   int          `xml:"portid,attr"`
+	State    nmapStatus   `xml:"state"`
+	Service  *nmapService `xml:"service"`
+	Scripts  []nmapScript `xml:"script"`
+}
+
+// nmapScript - результат скрипта NSE. Приветствие сервиса выводится
+// так же, как его выводит скрипт banner
+type nmapScript struct {
+	ID     string `xml:"id,attr"`
+	Output string `xml:"output,attr"`
+}
+
+type nmapService struct {
+	Name    string `xml:"name,attr"`
+	Product string `xml:"product,attr,omitempty"`
+	Version string `xml:"version,attr,omitempty"`
+	Method  string `xml:"method,attr"`
+	Conf    int    `xml:"conf,attr"`
+}
+
+type nmapRunStats struct {
+	XMLName  xml.Name     `xml:"runstats"`
+	Finished nmapFinished `xml:"finished"`
+	Hosts    nmapHosts    `xml:"hosts"`
+}
+
+type nmapFinished struct {
+	Time    int64  `xml:"time,attr"`
+	TimeStr string `xml:"timestr,attr"`
+	Elapsed string `xml:"elapsed,attr"`
+	Summary string `xml:"summary,attr"`
+	Exit    string `xml:"exit,attr"`
+}
+
+type nmapHosts struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+// nmapSSLCert возвращает данные сертификата в формате вывода
+// скрипта ssl-cert
+func nmapSSLCert(t *TLSInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Subject: %s\n", t.Subject)
+	if len(t.SANs) > 0 {
+		fmt.Fprintf(&b, "Subject Alternative Name: %s\n", strings.Join(t.SANs, ", "))
+	}
+	fmt.Fprintf(&b, "Issuer: %s\n", t.Issuer)
+	fmt.Fprintf(&b, "Not valid before: %s\n", t.NotBefore.UTC().Format("2006-01-02T15:04:05"))
+	fmt.Fprintf(&b, "Not valid after:  %s", t.NotAfter.UTC().Format("2006-01-02T15:04:05"))
+	return b.String()
+}
+
+// nmapState возвращает состояние порта и его причину в терминах nmap
+func nmapState(p PortState) (string, string) {
+	udp := p.protocol() == "udp"
+	switch p.Status {
+	case StatusOpen:
+		if udp {
+			return "open", "udp-response"
+		}
+		return "open", "syn-ack"
+	case StatusClosed:
+		if udp {
+			return "closed", "port-unreach"
+		}
+		return "closed", "conn-refused"
+	case StatusFiltered:
+		if udp {
+			return "filtered", "host-unreach"
+		}
+		return "filtered", "no-response"
+	case StatusOpenFiltered:
+		return "open|filtered", "no-response"
+	default:
+		return "filtered", "error"
+	}
+}
+
+// nmapScanType возвращает тип сканирования и протокол для scaninfo:
+// udp, если сканировались UDP порты, иначе TCP connect
+func nmapScanType(results []Results) (string, string) {
+	for _, r := range results {
+		for _, p := range r.PortStates {
+			if p.protocol() == "udp" {
+				return "udp", "udp"
+			}
+		}
+	}
+	return "connect", "tcp"
+}
+
+// nmapAddr возвращает адрес хоста и его тип для вывода в формате nmap
+func nmapAddr(r Results) (string, string) {
+	addr := hostAddr(r)
+	if a, err := netip.ParseAddr(addr); err == nil && a.Is6() && !a.Is4In6() {
+		return addr, "ipv6"
+	}
+	return addr, "ipv4"
+}
+
+// nmapHostnameOf возвращает имя хоста, если он был задан не адресом
+func nmapHostnameOf(r Results) string {
+	if _, err := netip.ParseAddr(r.Host); err == nil {
+		return ""
+	}
+	return r.Host
+}
+
+// nmapSummary собирает общие сведения о сканировании: время начала,
+// список портов и количество найденных и ненайденных хостов
+func nmapSummary(results []Results) (start time.Time, ports []int, up, down int) {
+	var lists [][]int
+	for _, r := range results {
+		if !r.ScannedAt.IsZero() && (start.IsZero() || r.ScannedAt.Before(start)) {
+			start = r.ScannedAt
+		}
+		if r.NotFound {
+			down++
+			continue
+		}
+		up++
+
+		l := make([]int, 0, len(r.PortStates))
+		for _, p := range r.PortStates {
+			l = append(l, p.Port)
+		}
+		lists = append(lists, l)
+	}
+
+	if start.IsZero() {
+		start = time.Now()
+	}
+	return start, MergePorts(lists...), up, down
+}
+
+// joinPorts возвращает список портов через запятую
+func joinPorts(ports []int) string {
+	s := make([]string, 0, len(ports))
+	for _, p := range ports {
+		s = append(s, strconv.Itoa(p))
+	}
+	return strings.Join(s, ",")
+}
+
+// WriteNmapXML записывает результаты сканирования в out в формате
+// XML вывода nmap (-oX). args - командная строка запуска сканирования.
+// Ненайденные хосты nmap в XML не выводит, поэтому они отмечаются
+// только комментарием
+func WriteNmapXML(out io.Writer, results []Results, args string) error {
+	start, ports, up, down := nmapSummary(results)
+	end := time.Now()
+
+	if _, err := io.WriteString(out, xml.Header+"<!DOCTYPE nmaprun>\n"); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+
+	// Элемент nmaprun открываем вручную, чтобы выводить хосты
+	// и комментарии потоком в исходном порядке
+	root := xml.StartElement{Name: xml.Name{Local: "nmaprun"}}
+	for _, a := range [][2]string{
+		// nmap.dtd допускает единственное значение атрибута scanner
+		{"scanner", "nmap"},
+		{"args", args},
+		{"start", strconv.FormatInt(start.Unix(), 10)},
+		{"startstr", start.Format(time.ANSIC)},
+		{"version", "pScan"},
+		{"xmloutputversion", nmapXMLVersion},
+	} {
+		root.Attr = append(root.Attr, xml.Attr{Name: xml.Name{Local: a[0]}, Value: a[1]})
+	}
+	if err := enc.EncodeToken(root); err != nil {
+		return err
+	}
+
+	scanType, protocol := nmapScanType(results)
+	scanInfo := nmapScanInfo{
+		Type:        scanType,
+		Protocol:    protocol,
+		NumServices: len(ports),
+		Services:    joinPorts(ports),
+	}
+	if err := enc.EncodeElement(scanInfo, xml.StartElement{Name: xml.Name{Local: "scaninfo"}}); err != nil {
+		return err
+	}
+	for _, name := range []string{"verbose", "debugging"} {
+		if err := enc.EncodeElement(nmapLevel{}, xml.StartElement{Name: xml.Name{Local: name}}); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range results {
+		if r.NotFound {
+			comment := fmt.Sprintf(" Failed to resolve %q ", r.Host)
+			if err := enc.EncodeToken(xml.Comment(comment)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		addr, addrType := nmapAddr(r)
+		h := nmapHost{
+			Status:  nmapStatus{State: "up", Reason: "user-set"},
+			Address: nmapAddress{Addr: addr, AddrType: addrType},
+		}
+		if !r.ScannedAt.IsZero() {
+			h.StartTime = r.ScannedAt.Unix()
+		}
+		if name := nmapHostnameOf(r); name != "" {
+			h.Hostnames.Hostnames = []nmapHostname{{Name: name, Type: "user"}}
+		}
+
+		for _, p := range r.PortStates {
+			state, reason := nmapState(p)
+			np := nmapPort{
+				Protocol: p.protocol(),
+				PortID:   p.Port,
+				State:    nmapStatus{State: state, Reason: reason},
+			}
+			if name := p.serviceName(); name != "" {
+				np.Service = &nmapService{Name: name, Method: "table", Conf: 3}
+				if p.probed() {
+					np.Service.Product = p.Product
+					np.Service.Version = p.Version
+					np.Service.Method = "probed"
+					np.Service.Conf = 10
+				}
+			}
+			if p.Banner != "" {
+				np.Scripts = append(np.Scripts, nmapScript{ID: "banner", Output: p.Banner})
+			}
+			if p.TLS != nil {
+				np.Scripts = append(np.Scripts, nmapScript{ID: "ssl-cert", Output: nmapSSLCert(p.TLS)})
+			}
+			h.Ports.Ports = append(h.Ports.Ports, np)
+		}
+
+		if err := enc.Encode(h); err != nil {
+			return err
+		}
+	}
+
+	stats := nmapRunStats{
+		Finished: nmapFinished{
+			Time:    end.Unix(),
+			TimeStr: end.Format(time.ANSIC),
+			Elapsed: fmt.Sprintf("%.2f", end.Sub(start).Seconds()),
+			Summary: nmapDoneSummary(up, down, start, end),
+			Exit:    "success",
+		},
+		Hosts: nmapHosts{Up: up, Down: down, Total: up + down},
+	}
+	if err := enc.Encode(stats); err != nil {
+		return err
+	}
+
+	if err := enc.EncodeToken(root.End()); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+// nmapDoneSummary возвращает итоговую строку сканирования в стиле nmap
+func nmapDoneSummary(up, down int, start, end time.Time) string {
+	return fmt.Sprintf("Nmap done at %s; %d IP address (%d host up) scanned in %.2f seconds",
+		end.Format(time.ANSIC), up+down, up, end.Sub(start).Seconds())
+}
+
+// WriteNmapGrepable записывает результаты сканирования в out
+// в "grepable" формате nmap (-oG). args - командная строка
+// запуска сканирования
+func WriteNmapGrepable(out io.Writer, results []Results, args string) error {
+	start, _, up, down := nmapSummary(results)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# pScan scan initiated %s as: %s\n", start.Format(time.ANSIC), args)
+
+	for _, r := range results {
+		if r.NotFound {
+			fmt.Fprintf(&b, "# Failed to resolve %q\n", r.Host)
+			continue
+		}
+
+		addr, _ := nmapAddr(r)
+		host := fmt.Sprintf("Host: %s (%s)", addr, nmapHostnameOf(r))
+		fmt.Fprintf(&b, "%s\tStatus: Up\n", host)
+
+		if len(r.PortStates) == 0 {
+			continue
+		}
+
+		ports := make([]string, 0, len(r.PortStates))
+		for _, p := range r.PortStates {
+			state, _ := nmapState(p)
+			// port/state/protocol/owner/service/rpc info/version/.
+			// В поле версии выводится программа сервиса с версией,
+			// а если они не определены - приветствие. Символы-разделители
+			// формата в нём заменяются
+			version := strings.TrimSpace(p.Product + " " + p.Version)
+			if version == "" {
+				version = bannerLine(p.Banner)
+			}
+			version = strings.NewReplacer("/", "|", ",", ";").Replace(version)
+			ports = append(ports, fmt.Sprintf("%d/%s/%s//%s//%s/", p.Port, state, p.protocol(), p.serviceName(), version))
+		}
+		fmt.Fprintf(&b, "%s\tPorts: %s\n", host, strings.Join(ports, ", "))
+	}
+
+	end := time.Now()
+	fmt.Fprintf(&b, "# pScan done at %s -- %d IP address (%d host up) scanned in %.2f seconds\n",
+		end.Format(time.ANSIC), up+down, up, end.Sub(start).Seconds())
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
@@ -0,0 +1,247 @@
+package scan_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+func TestWriteNmapXML(t *testing.T) {
+	var out bytes.Buffer
+	if err := scan.WriteNmapXML(&out, testResults(), "pScan scan"); err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	if !strings.Contains(out.String(), "<!DOCTYPE nmaprun>") {
+		t.Errorf("Ожидали объявление DOCTYPE nmaprun в выводе:\n%s", out.String())
+	}
+
+	var run struct {
+		Scanner  string `xml:"scanner,attr"`
+		Args     string `xml:"args,attr"`
+		ScanInfo struct {
+			Type     string `xml:"type,attr"`
+			Services string `xml:"services,attr"`
+		} `xml:"scaninfo"`
+		Hosts []struct {
+			Status struct {
+				State string `xml:"state,attr"`
+			} `xml:"status"`
+			Address struct {
+				Addr     string `xml:"addr,attr"`
+				AddrType string `xml:"addrtype,attr"`
+			} `xml:"address"`
+			Hostnames []struct {
+				Name string `xml:"name,attr"`
+			} `xml:"hostnames>hostname"`
+			Ports []struct {
+				PortID int `xml:"portid,attr"`
+				State  struct {
+					State string `xml:"state,attr"`
+				} `xml:"state"`
+				Service struct {
+					Name    string `xml:"name,attr"`
+					Product string `xml:"product,attr"`
+					Version string `xml:"version,attr"`
+					Method  string `xml:"method,attr"`
+				} `xml:"service"`
+				Scripts []struct {
+					ID     string `xml:"id,attr"`
+					Output string `xml:"output,attr"`
+				} `xml:"script"`
+			} `xml:"ports>port"`
+		} `xml:"host"`
+		RunStats struct {
+			Hosts struct {
+				Up    int `xml:"up,attr"`
+				Down  int `xml:"down,attr"`
+				Total int `xml:"total,attr"`
+			} `xml:"hosts"`
+		} `xml:"runstats"`
+	}
+
+	if err := xml.Unmarshal(out.Bytes(), &run); err != nil {
+		t.Fatalf("Ошибка разбора XML: %q\n", err)
+	}
+
+	if run.Scanner != "nmap" || run.Args != "pScan scan" {
+		t.Errorf("Неверные атрибуты nmaprun: %q, %q\n", run.Scanner, run.Args)
+	}
+
+	if run.ScanInfo.Type != "connect" || run.ScanInfo.Services != "22,25" {
+		t.Errorf("Неверный элемент scaninfo: %+v\n", run.ScanInfo)
+	}
+
+	// Ненайденный хост в XML не выводится
+	if len(run.Hosts) != 1 {
+		t.Fatalf("Ожидали 1 хост, а получили %d\n", len(run.Hosts))
+	}
+
+	h := run.Hosts[0]
+	if h.Status.State != "up" || h.Address.Addr != "127.0.0.1" || h.Address.AddrType != "ipv4" {
+		t.Errorf("Неверные данные хоста: %+v\n", h)
+	}
+
+	if len(h.Hostnames) != 1 || h.Hostnames[0].Name != "localhost" {
+		t.Errorf("Ожидали имя хоста localhost, а получили %+v\n", h.Hostnames)
+	}
+
+	if len(h.Ports) != 2 {
+		t.Fatalf("Ожидали 2 порта, а получили %d\n", len(h.Ports))
+	}
+
+	if h.Ports[0].PortID != 22 || h.Ports[0].State.State != "open" || h.Ports[0].Service.Name != "ssh" {
+		t.Errorf("Неверные данные порта: %+v\n", h.Ports[0])
+	}
+
+	if s := h.Ports[0].Service; s.Product != "OpenSSH" || s.Version != "9.6" || s.Method != "probed" {
+		t.Errorf("Неверный сервис порта: %+v\n", s)
+	}
+
+	if s := h.Ports[0].Scripts; len(s) != 1 || s[0].ID != "banner" || s[0].Output != "SSH-2.0-OpenSSH_9.6" {
+		t.Errorf("Ожидали скрипт banner, а получили %+v\n", s)
+	}
+
+	if h.Ports[1].PortID != 25 || h.Ports[1].State.State != "closed" || len(h.Ports[1].Scripts) != 0 {
+		t.Errorf("Неверные данные порта: %+v\n", h.Ports[1])
+	}
+
+	if run.RunStats.Hosts.Up != 1 || run.RunStats.Hosts.Down != 1 || run.RunStats.Hosts.Total != 2 {
+		t.Errorf("Неверная статистика хостов: %+v\n", run.RunStats.Hosts)
+	}
+}
+
+func TestWriteNmapGrepable(t *testing.T) {
+	var out bytes.Buffer
+	if err := scan.WriteNmapGrepable(&out, testResults(), "pScan scan"); err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Ожидали 5 строк, а получили %d:\n%s", len(lines), out.String())
+	}
+
+	expected := []string{
+		"Host: 127.0.0.1 (localhost)\tStatus: Up",
+		"Host: 127.0.0.1 (localhost)\tPorts: 22/open/tcp//ssh//OpenSSH 9.6/, 25/closed/tcp//smtp///",
+		"# Failed to resolve \"not-found-host\"",
+	}
+	for i, e := range expected {
+		if lines[i+1] != e {
+			t.Errorf("Ожидали строку %q, а получили %q\n", e, lines[i+1])
+		}
+	}
+
+	if !strings.HasPrefix(lines[0], "# pScan scan initiated ") || !strings.HasPrefix(lines[4], "# pScan done at ") {
+		t.Errorf("Неверные строки комментариев:\n%s", out.String())
+	}
+}
+
+// nmapRequired - подмножество nmap.dtd: обязательные (#REQUIRED)
+// атрибуты элементов, которые выводит WriteNmapXML, по пути элемента
+var nmapRequired = map[string][]string{
+	"nmaprun":                         {"scanner", "version", "xmloutputversion"},
+	"nmaprun/scaninfo":                {"type", "protocol", "numservices", "services"},
+	"nmaprun/host/status":             {"state", "reason", "reason_ttl"},
+	"nmaprun/host/address":            {"addr"},
+	"nmaprun/host/ports/port":         {"protocol", "portid"},
+	"nmaprun/host/ports/port/state":   {"state", "reason", "reason_ttl"},
+	"nmaprun/host/ports/port/service": {"name", "method", "conf"},
+	"nmaprun/host/ports/port/script":  {"id", "output"},
+	"nmaprun/runstats/finished":       {"time", "elapsed"},
+	"nmaprun/runstats/hosts":          {"up", "down", "total"},
+}
+
+// nmapValues - допустимые значения перечислимых атрибутов nmap.dtd
+var nmapValues = map[string][]string{
+	"nmaprun/scaninfo@type":                  {"syn", "ack", "bounce", "connect", "null", "xmas", "window", "maimon", "fin", "udp", "sctpinit", "sctpcookieecho", "ipproto"},
+	"nmaprun/scaninfo@protocol":              {"ip", "tcp", "udp", "sctp"},
+	"nmaprun/host/status@state":              {"up", "down", "unknown", "skipped"},
+	"nmaprun/host/address@addrtype":          {"ipv4", "ipv6", "mac"},
+	"nmaprun/host/hostnames/hostname@type":   {"user", "PTR"},
+	"nmaprun/host/ports/port@protocol":       {"ip", "tcp", "udp", "sctp"},
+	"nmaprun/host/ports/port/service@method": {"table", "probed"},
+	"nmaprun/host/ports/port/service@conf":   {"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10"},
+	"nmaprun/runstats/finished@exit":         {"error", "success"},
+}
+
+func TestWriteNmapXMLRequiredAttributes(t *testing.T) {
+	results := testResults()
+	results[0].PortStates = append(results[0].PortStates,
+		scan.PortState{Port: 53, Protocol: "udp", Status: scan.StatusOpenFiltered})
+
+	var out bytes.Buffer
+	if err := scan.WriteNmapXML(&out, results, "pScan scan"); err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	var (
+		path     []string
+		children []string
+		seen     = make(map[string]int)
+	)
+	dec := xml.NewDecoder(&out)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Ошибка разбора XML: %q\n", err)
+		}
+
+		switch el := tok.(type) {
+		case xml.StartElement:
+			path = append(path, el.Name.Local)
+			p := strings.Join(path, "/")
+			seen[p]++
+			if len(path) == 2 {
+				children = append(children, el.Name.Local)
+			}
+
+			attrs := make(map[string]string, len(el.Attr))
+			for _, a := range el.Attr {
+				attrs[a.Name.Local] = a.Value
+			}
+			for _, name := range nmapRequired[p] {
+				if _, ok := attrs[name]; !ok {
+					t.Errorf("Элемент %s: нет обязательного атрибута %s\n", p, name)
+				}
+			}
+			for name, v := range attrs {
+				allowed, ok := nmapValues[p+"@"+name]
+				if ok && !slices.Contains(allowed, v) {
+					t.Errorf("Элемент %s: недопустимое значение атрибута %s=%q\n", p, name, v)
+				}
+			}
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		}
+	}
+
+	// nmap.dtd: nmaprun (scaninfo*, verbose, debugging, host*, runstats)
+	exp := []string{"scaninfo", "verbose", "debugging", "host", "runstats"}
+	if !slices.Equal(children, exp) {
+		t.Errorf("Ожидали элементы nmaprun %q, а получили %q\n", exp, children)
+	}
+
+	for p, n := range map[string]int{
+		"nmaprun/host/status":             1,
+		"nmaprun/host/ports/port":         3,
+		"nmaprun/host/ports/port/state":   3,
+		"nmaprun/host/ports/port/service": 3,
+		"nmaprun/runstats/finished":       1,
+		"nmaprun/runstats/hosts":          1,
+	} {
+		if seen[p] != n {
+			t.Errorf("Ожидали %d элементов %s, а получили %d\n", n, p, seen[p])
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package scan
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// MinPort и MaxPort - допустимые границы номера TCP/UDP порта
+	MinPort = 1
+	MaxPort = 65535
+)
+
+var (
+	ErrInvalidPort    = errors.New("недопустимый номер порта")
+	ErrInvalidRange   = errors.New("недопустимый диапазон портов")
+	ErrUnknownService = errors.New("неизвестный сервис")
+	ErrEmptyPorts     = errors.New("не задано ни одного порта")
+	ErrTopPorts       = errors.New("недопустимое количество популярных портов")
+)
+
+//go:embed services.txt
+var servicesData string
+
+//go:embed top-ports.txt
+var topPortsData string
+
+var (
+	topPortsOnce sync.Once
+	// topPorts - популярные порты в порядке убывания частоты
+	topPorts []int
+)
+
+var (
+	servicesOnce sync.Once
+	// services - номера портов по именам сервисов и их синонимам
+	services map[string]int
+	// serviceNames - основные имена сервисов по номерам портов
+	serviceNames map[int]string
+)
+
+// loadServices разбирает встроенную таблицу сервисов. Имена
+// UDP сервисов используются только для поиска номера порта
+func loadServices() {
+	services = make(map[string]int)
+	serviceNames = make(map[int]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(servicesData))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		portStr, proto, _ := strings.Cut(fields[1], "/")
+		if proto != "tcp" && proto != "udp" {
+			continue
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := serviceNames[port]; !ok && proto == "tcp" {
+			serviceNames[port] = fields[0]
+		}
+
+		for _, name := range append([]string{fields[0]}, fields[2:]...) {
+			if _, ok := services[name]; !ok {
+				services[name] = port
+			}
+		}
+	}
+}
+
+// LookupService возвращает номер порта сервиса по его имени
+func LookupService(name string) (int, bool) {
+	servicesOnce.Do(loadServices)
+	port, ok := services[strings.ToLower(name)]
+	return port, ok
+}
+
+// loadTopPorts разбирает встроенную таблицу популярных портов
+func loadTopPorts() {
+	scanner := bufio.NewScanner(strings.NewReader(topPortsData))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		port, err := strconv.Atoi(strings.TrimSpace(line))
+		if err != nil {
+			continue
+		}
+		topPorts = append(topPorts, port)
+	}
+}
+
+// TopPorts возвращает n наиболее распространённых TCP портов
+// в порядке убывания частоты
+func TopPorts(n int) ([]int, error) {
+	topPortsOnce.Do(loadTopPorts)
+	if n < 1 || n > len(topPorts) {
+		return nil, fmt.Errorf("%w: %d (допустимо 1-%d)", ErrTopPorts, n, len(topPorts))
+	}
+	return slices.Clone(topPorts[:n]), nil
+}
+
+// MergePorts объединяет списки портов в порядке первого упоминания
+// без повторов
+func MergePorts(lists ...[]int) []int {
+	var (
+		ports []int
+		seen  = make(map[int]bool)
+	)
+	for _, l := range lists {
+		for _, p := range l {
+			if !seen[p] {
+				seen[p] = true
+				ports = append(ports, p)
+			}
+		}
+	}
+	return ports
+}
+
+// ServiceName возвращает имя известного сервиса на порту port
+// или пустую строку
+func ServiceName(port int) string {
+	servicesOnce.Do(loadServices)
+	return serviceNames[port]
+}
+
+// ValidatePort проверяет, что port - допустимый номер порта
+func ValidatePort(port int) error {
+	if port < MinPort || port > MaxPort {
+		return fmt.Errorf("%w: %d (допустимо %d-%d)", ErrInvalidPort, port, MinPort, MaxPort)
+	}
+	return nil
+}
+
+// ParsePorts разбирает спецификацию портов - список элементов через
+// запятую. Элемент может быть номером порта ("22"), диапазоном
+// ("8000-8100"), именем сервиса ("ssh") или исключением любого из
+// них с префиксом "!" ("!25", "!8080-8090").
+//
+// Порты возвращаются в порядке первого упоминания, без повторов
+// и без исключённых
+func ParsePorts(spec string) ([]int, error) {
+	include, exclude, err := ParsePortSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := ExcludePorts(include, exclude)
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrEmptyPorts, spec)
+	}
+	return ports, nil
+}
+
+// ParsePortSpec разбирает спецификацию портов так же, как ParsePorts,
+// но возвращает включённые и исключённые ("!") порты по отдельности
+// и не требует, чтобы остался хотя бы один порт. Так исключения
+// одной спецификации можно применить к портам из других источников
+func ParsePortSpec(spec string) (include, exclude []int, err error) {
+	seen := make(map[int]bool)
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		excluded := strings.HasPrefix(item, "!")
+		item = strings.TrimPrefix(item, "!")
+
+		from, to, err := parsePortItem(item)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for p := from; p <= to; p++ {
+			if excluded {
+				exclude = append(exclude, p)
+				continue
+			}
+			if !seen[p] {
+				seen[p] = true
+				include = append(include, p)
+			}
+		}
+	}
+	return include, exclude, nil
+}
+
+// ExcludePorts возвращает порты из ports, кроме перечисленных в exclude
+func ExcludePorts(ports, exclude []int) []int {
+	excluded := make(map[int]bool, len(exclude))
+	for _, p := range exclude {
+		excluded[p] = true
+	}
+
+	var result []int
+	for _, p := range ports {
+		if !excluded[p] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// parsePortItem разбирает один элемент спецификации портов
+// и возвращает границы диапазона включительно
+func parsePortItem(item string) (int, int, error) {
+	// Имена некоторых сервисов содержат дефис, например ftp-data
+	if port, ok := LookupService(item); ok {
+		return port, port, nil
+	}
+
+	if fromStr, toStr, ok := strings.Cut(item, "-"); ok {
+		from, err := parsePort(fromStr)
+		if err != nil {
+			return 0, 0, err
+		}
+		to, err := parsePort(toStr)
+		if err != nil {
+			return 0, 0, err
+		}
+		if from > to {
+			return 0, 0, fmt.Errorf("%w: %q", ErrInvalidRange, item)
+		}
+		return from, to, nil
+	}
+
+	port, err := parsePort(item)
+	if err != nil {
+		return 0, 0, err
+	}
+	return port, port, nil
+}
+
+// parsePort разбирает номер порта или имя сервиса
+func parsePort(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("%w: пустое значение", ErrInvalidPort)
+	}
+
+	port, err := strconv.Atoi(s)
+	if err != nil {
+		var ok bool
+		if port, ok = LookupService(s); !ok {
+			return 0, fmt.Errorf("%w: %q", ErrUnknownService, s)
+		}
+	}
+
+	if err := ValidatePort(port); err != nil {
+		return 0, err
+	}
+	return port, nil
+}
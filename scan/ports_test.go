@@ -0,0 +1,138 @@
+package scan_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+func TestParsePorts(t *testing.T) {
+	testCases := []struct {
+		name        string
+		spec        string
+		expected    []int
+		expectedErr error
+	}{
+		{"Single", "22", []int{22}, nil},
+		{"List", "22,80,443", []int{22, 80, 443}, nil},
+		{"Range", "8000-8003", []int{8000, 8001, 8002, 8003}, nil},
+		{"Mixed", "22, 80,8000-8002", []int{22, 80, 8000, 8001, 8002}, nil},
+		{"Services", "ssh,https", []int{22, 443}, nil},
+		{"ServiceWithDash", "ftp-data", []int{20}, nil},
+		{"ServiceAlias", "cmd", []int{514}, nil},
+		{"UDPService", "syslog", []int{514}, nil},
+		{"ServiceRange", "ftp-ssh", []int{21, 22}, nil},
+		{"Duplicates", "80,79-81,http", []int{80, 79, 81}, nil},
+		{"Exclude", "20-26,!25,!ftp", []int{20, 22, 23, 24, 26}, nil},
+		{"ExcludeRange", "1-10,!2-9", []int{1, 10}, nil},
+		{"Zero", "0", nil, scan.ErrInvalidPort},
+		{"TooBig", "70000", nil, scan.ErrInvalidPort},
+		{"RangeTooBig", "65530-65536", nil, scan.ErrInvalidPort},
+		{"Reversed", "100-10", nil, scan.ErrInvalidRange},
+		{"Unknown", "no-such-service", nil, scan.ErrUnknownService},
+		{"OpenRange", "10-", nil, scan.ErrInvalidPort},
+		{"AllExcluded", "25,!25", nil, scan.ErrEmptyPorts},
+		{"Empty", "", nil, scan.ErrEmptyPorts},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ports, err := scan.ParsePorts(tc.spec)
+
+			if tc.expectedErr != nil {
+				if !errors.Is(err, tc.expectedErr) {
+					t.Fatalf("Ожидали ошибку %q, а получили %v\n", tc.expectedErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+			}
+
+			if !slices.Equal(ports, tc.expected) {
+				t.Errorf("Ожидали порты %v, а получили %v\n", tc.expected, ports)
+			}
+		})
+	}
+}
+
+func TestParsePortSpec(t *testing.T) {
+	include, exclude, err := scan.ParsePortSpec("22,80-81,!25,!http")
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+	if !slices.Equal(include, []int{22, 80, 81}) || !slices.Equal(exclude, []int{25, 80}) {
+		t.Errorf("Ожидали порты [22 80 81] без [25 80], а получили %v без %v\n", include, exclude)
+	}
+
+	// Одни исключения - не ошибка: их применят к другим источникам
+	include, exclude, err = scan.ParsePortSpec("!23")
+	if err != nil || include != nil || !slices.Equal(exclude, []int{23}) {
+		t.Errorf("Ожидали только исключение 23, а получили %v, %v, %v\n", include, exclude, err)
+	}
+
+	if ports := scan.ExcludePorts([]int{22, 23, 80}, exclude); !slices.Equal(ports, []int{22, 80}) {
+		t.Errorf("Ожидали порты [22 80], а получили %v\n", ports)
+	}
+}
+
+func TestRunContextInvalidPort(t *testing.T) {
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+
+	for _, p := range []int{0, -1, 70000} {
+		_, err := scan.RunContext(context.Background(), hl, []int{p}, scan.Options{})
+		if !errors.Is(err, scan.ErrInvalidPort) {
+			t.Errorf("Ожидали ошибку %q для порта %d, а получили %v\n", scan.ErrInvalidPort, p, err)
+		}
+	}
+}
+
+func TestTopPorts(t *testing.T) {
+	ports, err := scan.TopPorts(10)
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	expected := []int{80, 23, 443, 21, 22, 25, 3389, 110, 445, 139}
+	if !slices.Equal(ports, expected) {
+		t.Errorf("Ожидали порты %v, а получили %v\n", expected, ports)
+	}
+
+	ports, err = scan.TopPorts(100)
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+	if len(scan.MergePorts(ports)) != 100 {
+		t.Errorf("Ожидали 100 различных портов, а получили %d\n", len(scan.MergePorts(ports)))
+	}
+
+	for _, n := range []int{0, 100000} {
+		if _, err := scan.TopPorts(n); !errors.Is(err, scan.ErrTopPorts) {
+			t.Errorf("Ожидали ошибку %q для %d, а получили %v\n", scan.ErrTopPorts, n, err)
+		}
+	}
+}
+
+func TestServiceName(t *testing.T) {
+	testCases := []struct {
+		port     int
+		expected string
+	}{
+		{22, "ssh"},
+		{25, "smtp"},
+		// 514/tcp - rsh, syslog работает только на 514/udp
+		{514, "shell"},
+		{65000, ""},
+	}
+
+	for _, tc := range testCases {
+		if name := scan.ServiceName(tc.port); name != tc.expected {
+			t.Errorf("Ожидали сервис %q для порта %d, а получили %q\n", tc.expected, tc.port, name)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultProbeTimeout - время ожидания ответа на пробу по умолчанию
+	DefaultProbeTimeout = 2 * time.Second
+
+	// maxProbeResponse - наибольший размер читаемого ответа на пробу
+	maxProbeResponse = 4096
+)
+
+var ErrProbeSyntax = errors.New("ошибка в таблице проб")
+
+//go:embed service-probes.txt
+var probesData string
+
+var (
+	probesOnce sync.Once
+	// probes - пробы определения сервисов в порядке выполнения
+	probes []probe
+)
+
+// probe - данные, отправляемые сервису, и правила распознавания ответа
+type probe struct {
+	name    string
+	payload []byte
+	// ports - порты пробы, nil - любые порты
+	ports   []int
+	matches []matchRule
+}
+
+// matchRule - правило распознавания ответа на пробу
+type matchRule struct {
+	service string
+	re      *regexp.Regexp
+	product string
+	version string
+	soft    bool
+}
+
+// ServiceInfo - сервис, определённый пробами
+type ServiceInfo struct {
+	Service string
+	Product string
+	Version string
+}
+
+// forPort сообщает, отправляется ли проба на порт port
+func (p probe) forPort(port int) bool {
+	return p.ports == nil || slices.Contains(p.ports, port)
+}
+
+// match применяет правила пробы к ответу resp. soft сообщает,
+// что совпало только неокончательное правило
+func (p probe) match(resp []byte) (info ServiceInfo, soft, ok bool) {
+	if len(resp) == 0 {
+		return ServiceInfo{}, false, false
+	}
+
+	// Ответ сопоставляется побайтно: каждый байт - отдельный символ,
+	// чтобы \xNN в выражениях соответствовали байтам, а не UTF-8
+	runes := make([]rune, len(resp))
+	for i, b := range resp {
+		runes[i] = rune(b)
+	}
+	s := string(runes)
+
+	for _, m := range p.matches {
+		groups := m.re.FindStringSubmatchIndex(s)
+		if groups == nil {
+			continue
+		}
+
+		info := ServiceInfo{
+			Service: m.service,
+			Product: string(m.re.ExpandString(nil, m.product, s, groups)),
+			Version: string(m.re.ExpandString(nil, m.version, s, groups)),
+		}
+		return info, m.soft, true
+	}
+	return ServiceInfo{}, false, false
+}
+
+// loadProbes разбирает встроенную таблицу проб. Ошибка во встроенной
+// таблице - ошибка программы, она обнаруживается тестами
+func loadProbes() {
+	var err error
+	if probes, err = parseProbes(probesData); err != nil {
+		panic(err)
+	}
+}
+
+// parseProbes разбирает таблицу проб в формате service-probes.txt
+func parseProbes(data string) ([]probe, error) {
+	var res []probe
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		directive, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+
+		var err error
+		switch directive {
+		case "Probe":
+			var p probe
+			p, err = parseProbe(rest)
+			res = append(res, p)
+		case "ports", "match", "softmatch":
+			if len(res) == 0 {
+				err = errors.New("директива до первой пробы")
+				break
+			}
+			p := &res[len(res)-1]
+			if directive == "ports" {
+				p.ports, err = ParsePorts(rest)
+				break
+			}
+
+			var m matchRule
+			m, err = parseMatch(rest)
+			m.soft = directive == "softmatch"
+			p.matches = append(p.matches, m)
+		default:
+			err = fmt.Errorf("неизвестная директива %q", directive)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: строка %d: %s", ErrProbeSyntax, n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Ответом на первую пробу считается приветствие сервиса
+	if len(res) == 0 || len(res[0].payload) > 0 {
+		return nil, fmt.Errorf("%w: первой должна быть проба без данных", ErrProbeSyntax)
+	}
+	return res, nil
+}
+
+// nullProbe возвращает пробу NULL, читающую приветствие сервиса
+func nullProbe() probe {
+	probesOnce.Do(loadProbes)
+	return probes[0]
+}
+
+// parseProbe разбирает директиву Probe: <имя> q|<данные>|
+func parseProbe(s string) (probe, error) {
+	name, rest, _ := strings.Cut(s, " ")
+	if name == "" {
+		return probe{}, errors.New("не указано имя пробы")
+	}
+
+	payload, rest, err := cutDelimited(strings.TrimSpace(rest), 'q')
+	if err != nil {
+		return probe{}, err
+	}
+	if rest != "" {
+		return probe{}, fmt.Errorf("лишние данные %q", rest)
+	}
+
+	data, err := unescapePayload(payload)
+	if err != nil {
+		return probe{}, err
+	}
+	return probe{name: name, payload: data}, nil
+}
+
+// parseMatch разбирает директиву match:
+// <сервис> m|<выражение>|[флаги] [p/<продукт>/] [v/<версия>/]
+func parseMatch(s string) (matchRule, error) {
+	service, rest, _ := strings.Cut(s, " ")
+	if service == "" {
+		return matchRule{}, errors.New("не указан сервис")
+	}
+
+	expr, rest, err := cutDelimited(strings.TrimSpace(rest), 'm')
+	if err != nil {
+		return matchRule{}, err
+	}
+
+	flags, rest, _ := strings.Cut(rest, " ")
+	if strings.Trim(flags, "si") != "" {
+		return matchRule{}, fmt.Errorf("неизвестные флаги %q", flags)
+	}
+	if flags != "" {
+		expr = "(?" + flags + ")" + expr
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return matchRule{}, err
+	}
+	m := matchRule{service: service, re: re}
+
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		var value string
+		field := rest[0]
+		switch field {
+		case 'p':
+			value, rest, err = cutDelimited(rest, 'p')
+			m.product = value
+		case 'v':
+			value, rest, err = cutDelimited(rest, 'v')
+			m.version = value
+		default:
+			err = fmt.Errorf("неизвестное поле %q", rest)
+		}
+		if err != nil {
+			return matchRule{}, err
+		}
+	}
+	return m, nil
+}
+
+// cutDelimited выделяет из s значение вида <prefix><разделитель>
+// <значение><разделитель> и возвращает его и остаток строки
+func cutDelimited(s string, prefix byte) (value, rest string, err error) {
+	if len(s) < 3 || s[0] != prefix {
+		return "", "", fmt.Errorf("ожидали %c|...| в %q", prefix, s)
+	}
+
+	delim := s[1]
+	end := strings.IndexByte(s[2:], delim)
+	if end < 0 {
+		return "", "", fmt.Errorf("нет закрывающего разделителя %c в %q", delim, s)
+	}
+	return s[2 : 2+end], s[2+end+1:], nil
+}
+
+// unescapePayload заменяет в данных пробы последовательности
+// \r, \n, \t, \0, \\ и \xNN соответствующими байтами
+func unescapePayload(s string) ([]byte, error) {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		if i+1 >= len(s) {
+			return nil, errors.New("незавершённая последовательность \\")
+		}
+
+		i++
+		switch s[i] {
+		case 'r':
+			b = append(b, '\r')
+		case 'n':
+			b = append(b, '\n')
+		case 't':
+			b = append(b, '\t')
+		case '0':
+			b = append(b, 0)
+		case '\\':
+			b = append(b, '\\')
+		case 'x':
+			if i+2 >= len(s) {
+				return nil, errors.New("незавершённая последовательность \\x")
+			}
+			c, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("недопустимая последовательность \\x%s", s[i+1:i+3])
+			}
+			b = append(b, byte(c))
+			i += 2
+		default:
+			return nil, fmt.Errorf("неизвестная последовательность \\%c", s[i])
+		}
+	}
+	return b, nil
+}
+
+// dialFunc устанавливает новое соединение с проверяемым портом
+type dialFunc func(ctx context.Context) (net.Conn, error)
+
+// readResponse читает ответ сервиса не дольше timeout. Чтение
+// прекращается, как только прочитанное распознано пробой p,
+// сервис закрыл соединение или истекло время ожидания
+func readResponse(conn net.Conn, p probe, timeout time.Duration) []byte {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil
+	}
+
+	buf := make([]byte, 0, maxProbeResponse)
+	for len(buf) < maxProbeResponse {
+		n, err := conn.Read(buf[len(buf):maxProbeResponse])
+		buf = buf[:len(buf)+n]
+		if err != nil {
+			break
+		}
+		if _, soft, ok := p.match(buf); ok && !soft {
+			break
+		}
+	}
+	return buf
+}
+
+// detectService определяет сервис на открытом порту port пробами
+// из таблицы service-probes.txt. greeting - приветствие, уже
+// прочитанное в первом соединении: это ответ на первую пробу.
+// Если пробы не дали результата, они повторяются поверх TLS
+func detectService(ctx context.Context, dial dialFunc, port int, greeting []byte, timeout time.Duration) ServiceInfo {
+	probesOnce.Do(loadProbes)
+
+	info, ok := runProbes(ctx, dial, port, greeting, timeout)
+	if ok {
+		return info
+	}
+
+	tlsDial := func(ctx context.Context) (net.Conn, error) {
+		conn, err := dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tc := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+
+		hctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		if err := tc.HandshakeContext(hctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tc, nil
+	}
+
+	conn, err := tlsDial(ctx)
+	if err != nil {
+		return info
+	}
+	tlsGreeting := readResponse(conn, nullProbe(), timeout)
+	conn.Close()
+
+	tlsInfo, _ := runProbes(ctx, tlsDial, port, tlsGreeting, timeout)
+	switch tlsInfo.Service {
+	case "":
+		tlsInfo.Service = "ssl"
+	case "http":
+		tlsInfo.Service = "https"
+	default:
+		tlsInfo.Service = "ssl/" + tlsInfo.Service
+	}
+	return tlsInfo
+}
+
+// runProbes выполняет пробы для порта port, каждую в новом
+// соединении. ok сообщает об окончательном совпадении; иначе
+// возвращается результат неокончательного совпадения, если он был
+func runProbes(ctx context.Context, dial dialFunc, port int, greeting []byte, timeout time.Duration) (info ServiceInfo, ok bool) {
+	var softInfo ServiceInfo
+
+	for i, p := range probes {
+		if !p.forPort(port) {
+			continue
+		}
+
+		resp := greeting
+		if i > 0 {
+			if ctx.Err() != nil {
+				break
+			}
+			resp = sendProbe(ctx, dial, p, timeout)
+		}
+
+		info, soft, ok := p.match(resp)
+		switch {
+		case ok && !soft:
+			return info, true
+		case ok && softInfo.Service == "":
+			softInfo = info
+		}
+	}
+	return softInfo, false
+}
+
+// sendProbe отправляет пробу p в новом соединении и возвращает ответ
+func sendProbe(ctx context.Context, dial dialFunc, p probe, timeout time.Duration) []byte {
+	conn, err := dial(ctx)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(p.payload); err != nil && !errors.Is(err, io.EOF) {
+		return nil
+	}
+	return readResponse(conn, p, timeout)
+}
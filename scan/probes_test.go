@@ -0,0 +1,110 @@
+package scan_test
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+// newServer запускает тестовый HTTP сервер с обработчиком h,
+// с TLS или без. Ошибки соединений от проб не журналируются
+func newServer(t *testing.T, h http.Handler, useTLS bool) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewUnstartedServer(h)
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+	if useTLS {
+		ts.StartTLS()
+	} else {
+		ts.Start()
+	}
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// serverPort возвращает порт тестового HTTP сервера
+func serverPort(t *testing.T, ts *httptest.Server) int {
+	t.Helper()
+
+	_, portStr, err := net.SplitHostPort(ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+func TestRunContextServiceDetect(t *testing.T) {
+	nginx := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.25.3")
+	})
+
+	testCases := []struct {
+		name       string
+		listen     func(t *testing.T) int
+		expService string
+		expProduct string
+		expVersion string
+	}{
+		{"SSH", func(t *testing.T) int {
+			return listenGreeting(t, "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13\r\n")
+		}, "ssh", "OpenSSH", "9.6p1"},
+		{"SMTP", func(t *testing.T) int {
+			return listenGreeting(t, "220 mail.example.com ESMTP Postfix (Ubuntu)\r\n")
+		}, "smtp", "Postfix smtpd", ""},
+		{"MySQL", func(t *testing.T) int {
+			return listenGreeting(t, "J\x00\x00\x00\x0a8.0.36\x00\x08\x00\x00\x00\xff\xf7")
+		}, "mysql", "MySQL", "8.0.36"},
+		{"HTTP", func(t *testing.T) int {
+			return serverPort(t, newServer(t, nginx, false))
+		}, "http", "nginx", "1.25.3"},
+		{"HTTPS", func(t *testing.T) int {
+			return serverPort(t, newServer(t, nginx, true))
+		}, "https", "nginx", "1.25.3"},
+		{"Unknown", func(t *testing.T) int {
+			return listenGreeting(t, "")
+		}, "", "", ""},
+	}
+
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			port := tc.listen(t)
+
+			opts := scan.Options{ServiceDetect: true, ProbeTimeout: 300 * time.Millisecond}
+			res, err := scan.RunContext(context.Background(), hl, []int{port}, opts)
+			if err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+			}
+
+			ps := res[0].PortStates[0]
+			if ps.Status != scan.StatusOpen {
+				t.Fatalf("Ожидали порт %d в состоянии open, а получили %s\n", port, ps.Status)
+			}
+
+			// Для неопознанного сервиса остаётся имя из таблицы сервисов
+			expService := tc.expService
+			if expService == "" {
+				expService = scan.ServiceName(port)
+			}
+
+			if ps.Service != expService || ps.Product != tc.expProduct || ps.Version != tc.expVersion {
+				t.Errorf("Ожидали %q %q %q, а получили %q %q %q\n",
+					expService, tc.expProduct, tc.expVersion, ps.Service, ps.Product, ps.Version)
+			}
+		})
+	}
+}
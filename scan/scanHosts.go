@@ -1,16 +1,78 @@
 package scan
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net"
+	"net/netip"
+	"sync"
+	"syscall"
 	"time"
 )
 
+const (
+	// DefaultWorkers - количество горутин сканирования, если в Options
+	// не задано иное
+	DefaultWorkers = 50
+
+	// DefaultTimeout - время ожидания соединения с портом по умолчанию
+	DefaultTimeout = 1 * time.Second
+)
+
+// state - тип устаревшего признака открытого порта PortState.Open.
+// Он различает только "open" и "closed": фильтруемый порт и порт
+// с ошибкой проверки тоже выводятся как "closed". Для вывода
+// состояния порта используйте PortState.Status.String()
 type state bool
 
+// Status - подробное состояние порта
+type Status int
+
+const (
+	// StatusClosed - хост отверг соединение (TCP RST)
+	StatusClosed Status = iota
+	// StatusOpen - соединение установлено
+	StatusOpen
+	// StatusFiltered - ответа не получено, вероятно, порт
+	// закрыт межсетевым экраном
+	StatusFiltered
+	// StatusError - проверка не удалась из-за локальной ошибки
+	// (нет маршрута, исчерпаны файловые дескрипторы и т.п.)
+	StatusError
+	// StatusOpenFiltered - UDP порт не ответил: он открыт, но сервис
+	// молчит, либо датаграммы отбрасывает межсетевой экран
+	StatusOpenFiltered
+)
+
 type PortState struct {
 	Port int
-	Open state
+	// Protocol - протокол порта: "udp" при Options.UDP,
+	// пустая строка означает TCP
+	Protocol string
+	// Open - признак открытого порта, сохранён для совместимости.
+	// Совпадает с Status == StatusOpen. Устарел: Open.String() не
+	// различает закрытые, фильтруемые порты и ошибки, вместо него
+	// используйте Status
+	Open   state
+	Status Status
+	// Reason - текст ошибки, по которой порт не признан открытым
+	Reason string
+	// Latency - длительность последней попытки соединения
+	Latency time.Duration
+	// Service - имя сервиса на открытом порту
+	Service string
+	// Banner - приветствие сервиса на открытом порту,
+	// заполняется при Options.Banner
+	Banner string
+	// Product и Version - программа сервиса и её версия,
+	// определённые пробами при Options.ServiceDetect
+	Product string
+	Version string
+	// TLS - параметры TLS и сертификат сервера на открытом порту,
+	// заполняется при Options.TLSInspect, если порт поддерживает TLS
+	TLS *TLSInfo
 }
 
 func (s state) String() string {
@@ -21,45 +83,580 @@ func (s state) String() string {
 	}
 }
 
-// scanPort проверяет, открыт ли порт port на хосте host
-func scanPort(host string, port int) PortState {
+// serviceName возвращает имя сервиса на порту: определённое
+// при сканировании или известное из таблицы сервисов
+func (p PortState) serviceName() string {
+	if p.Service != "" {
+		return p.Service
+	}
+	if p.Protocol == "udp" {
+		return udpServiceName(p.Port)
+	}
+	return ServiceName(p.Port)
+}
+
+// probed сообщает, что сервис порта определён пробами, а не взят
+// из таблицы сервисов: пробы находят программу или её версию
+func (p PortState) probed() bool {
+	return p.Product != "" || p.Version != ""
+}
+
+// protocol возвращает протокол порта, по умолчанию tcp
+func (p PortState) protocol() string {
+	if p.Protocol == "" {
+		return "tcp"
+	}
+	return p.Protocol
+}
+
+func (s Status) String() string {
+	switch s {
+	case StatusOpen:
+		return "open"
+	case StatusClosed:
+		return "closed"
+	case StatusFiltered:
+		return "filtered"
+	case StatusError:
+		return "error"
+	case StatusOpenFiltered:
+		return "open|filtered"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// MarshalText кодирует состояние порта его именем
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText декодирует состояние порта из его имени
+func (s *Status) UnmarshalText(text []byte) error {
+	st, err := ParseStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*s = st
+	return nil
+}
+
+var ErrUnknownStatus = errors.New("неизвестное состояние порта")
+
+// ParseStatus возвращает состояние порта по его имени,
+// полученному из Status.String()
+func ParseStatus(s string) (Status, error) {
+	for _, st := range []Status{StatusOpen, StatusClosed, StatusFiltered, StatusError, StatusOpenFiltered} {
+		if st.String() == s {
+			return st, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnknownStatus, s)
+}
+
+// classify определяет состояние порта по ошибке соединения
+func classify(err error) Status {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return StatusClosed
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return StatusFiltered
+	}
+
+	return StatusError
+}
+
+// scanPort проверяет, открыт ли порт port на хосте host.
+// Если addr не пуст, соединение устанавливается с этим адресом,
+// а host используется только как имя сервера для TLS.
+// Порт считается закрытым только после неудачи всех 1+opts.Retries
+// попыток соединения. Перед каждым соединением, в том числе для
+// проб и TLS, соблюдаются ограничения скорости th.
+// Ошибка возвращается только в случае отмены ctx - тогда
+// состояние порта неизвестно
+func scanPort(ctx context.Context, host, addr string, port int, opts Options, th *throttle) (PortState, error) {
 	p := PortState{
 		Port: port,
 	}
-	address := net.JoinHostPort(host, fmt.Sprintf("%d", port))
-	scanConn, err := net.DialTimeout("tcp", address, 1*time.Second)
-	if err != nil {
-		return p
+	if addr == "" {
+		addr = host
+	}
+	if opts.UDP {
+		return scanUDPPort(ctx, addr, port, opts, th)
+	}
+	address := net.JoinHostPort(addr, fmt.Sprintf("%d", port))
+	d := opts.dialer()
+	dial := func(ctx context.Context) (net.Conn, error) {
+		if err := th.beforeDial(ctx); err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(ctx, opts.timeout())
+		defer cancel()
+		return d.DialContext(ctx, "tcp", address)
 	}
 
-	scanConn.Close()
-	p.Open = true
-	return p
+	for attempt := 0; attempt <= opts.retries(); attempt++ {
+		if attempt > 0 && opts.Backoff > 0 {
+			if err := sleep(ctx, opts.Backoff); err != nil {
+				return p, err
+			}
+		}
+
+		start := time.Now()
+		scanConn, err := dial(ctx)
+		p.Latency = time.Since(start)
+		if err != nil {
+			if ctx.Err() != nil {
+				return p, ctx.Err()
+			}
+			p.Status = classify(err)
+			p.Reason = err.Error()
+			continue
+		}
+
+		var greeting []byte
+		switch {
+		case opts.ServiceDetect:
+			greeting = readResponse(scanConn, nullProbe(), opts.probeTimeout())
+		case opts.Banner:
+			greeting = readGreeting(scanConn, opts.bannerTimeout())
+		}
+		if opts.Banner {
+			p.Banner = sanitizeBanner(greeting[:min(len(greeting), MaxBannerSize)])
+		}
+		scanConn.Close()
+
+		p.Open = true
+		p.Status = StatusOpen
+		p.Reason = ""
+		p.Service = ServiceName(port)
+
+		if opts.ServiceDetect {
+			info := detectService(ctx, dial, port, greeting, opts.probeTimeout())
+			if info.Service != "" {
+				p.Service = info.Service
+			}
+			p.Product = info.Product
+			p.Version = info.Version
+		}
+
+		if opts.TLSInspect {
+			p.TLS = inspectTLS(ctx, dial, host, opts.probeTimeout(), opts.expiryWarning())
+		}
+		return p, nil
+	}
+
+	return p, nil
 }
 
 type Results struct {
-	Host       string
-	NotFound   bool
+	Host string
+	// Addresses - адреса, в которые разрешилось имя хоста
+	Addresses []string
+	// IP - адрес, на котором сканировались порты, при
+	// Options.IPVersion. Пуст, если адрес выбирал резолвер
+	IP       string
+	NotFound bool
+	// ScannedAt - время начала сканирования хоста
+	ScannedAt  time.Time
 	PortStates []PortState
 }
 
+// Target возвращает имя хоста вместе с адресом сканирования,
+// если он задан и отличается от имени: "example.com (2001:db8::1)".
+// Отличает результаты одного хоста на разных адресах
+func (r Results) Target() string {
+	if r.IP == "" || r.IP == r.Host {
+		return r.Host
+	}
+	return fmt.Sprintf("%s (%s)", r.Host, r.IP)
+}
+
+// Options - параметры сканирования
+type Options struct {
+	// Workers - размер пула горутин, одновременно выполняющих
+	// проверку портов. Значение <= 0 означает DefaultWorkers
+	Workers int
+
+	// Timeout - время ожидания одной попытки соединения.
+	// Значение <= 0 означает DefaultTimeout
+	Timeout time.Duration
+
+	// Retries - количество повторных попыток соединения
+	// после первой неудачной
+	Retries int
+
+	// Backoff - пауза между попытками соединения
+	Backoff time.Duration
+
+	// MaxHosts - наибольшее количество адресов, получаемых при
+	// раскрытии подсетей и диапазонов из списка хостов.
+	// Значение <= 0 означает DefaultMaxHosts
+	MaxHosts int
+
+	// Banner включает чтение приветствия сервиса на открытых портах
+	Banner bool
+
+	// BannerTimeout - время ожидания приветствия.
+	// Значение <= 0 означает DefaultBannerTimeout
+	BannerTimeout time.Duration
+
+	// ServiceDetect включает определение сервиса и его версии
+	// на открытых портах пробами из таблицы service-probes.txt
+	ServiceDetect bool
+
+	// ProbeTimeout - время ожидания ответа на одну пробу, в том
+	// числе приветствия при ServiceDetect.
+	// Значение <= 0 означает DefaultProbeTimeout
+	ProbeTimeout time.Duration
+
+	// TLSInspect включает TLS рукопожатие с открытыми портами
+	// и получение сертификата сервера. Время ожидания
+	// рукопожатия - ProbeTimeout
+	TLSInspect bool
+
+	// ExpiryWarning - за сколько до истечения срока действия
+	// сертификат считается истекающим.
+	// Значение <= 0 означает DefaultExpiryWarning
+	ExpiryWarning time.Duration
+
+	// Rate - наибольшее количество новых соединений в секунду
+	// на всё сканирование. Значение <= 0 - без ограничения
+	Rate float64
+
+	// HostConcurrency - наибольшее количество одновременных
+	// проверок портов одного хоста, общее для всех его адресов.
+	// Значение <= 0 - без ограничения
+	HostConcurrency int
+
+	// Jitter - наибольшая случайная пауза перед каждым соединением
+	Jitter time.Duration
+
+	// Randomize включает проверку пар хост/порт в случайном порядке.
+	// Порядок определяется Seed: с одним значением Seed он
+	// повторяется. Порядок результатов от этого не зависит
+	Randomize bool
+	Seed      uint64
+
+	// RandomSeed - каждый вызов RunContext выбирает для Randomize
+	// новое случайное начальное значение вместо Seed
+	RandomSeed bool
+
+	// IPVersion - семейство адресов для сканирования. Кроме IPAny,
+	// каждый адрес хоста выбранного семейства сканируется отдельно
+	// и получает собственный Results с заполненным IP
+	IPVersion IPVersion
+
+	// UDP включает сканирование UDP портов вместо TCP. Приветствие
+	// при Banner - ответ на датаграмму, а ServiceDetect и TLSInspect
+	// не применяются
+	UDP bool
+
+	// Dialer устанавливает соединения с портами, в том числе для
+	// проб, TLS и UDP. Позволяет направить сканирование через прокси
+	// или подменить сеть в тестах. nil означает net.Dialer
+	Dialer Dialer
+
+	// Resolver разрешает имена хостов в адреса. Позволяет
+	// использовать собственный DNS сервер или подменить разрешение
+	// имён в тестах. nil означает net.DefaultResolver
+	Resolver Resolver
+}
+
+// Dialer устанавливает соединения для сканирования. Время ожидания
+// соединения передаётся через ctx. Ему удовлетворяет *net.Dialer
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Resolver разрешает имена хостов в адреса сети network: "ip",
+// "ip4" или "ip6". Ему удовлетворяет *net.Resolver
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// resolver возвращает фактический Resolver
+func (o Options) resolver() Resolver {
+	if o.Resolver == nil {
+		return net.DefaultResolver
+	}
+	return o.Resolver
+}
+
+// dialer возвращает фактический Dialer
+func (o Options) dialer() Dialer {
+	if o.Dialer == nil {
+		return &net.Dialer{}
+	}
+	return o.Dialer
+}
+
+// workers возвращает фактический размер пула горутин
+func (o Options) workers() int {
+	if o.Workers <= 0 {
+		return DefaultWorkers
+	}
+	return o.Workers
+}
+
+// timeout возвращает фактическое время ожидания соединения
+func (o Options) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return o.Timeout
+}
+
+// bannerTimeout возвращает фактическое время ожидания приветствия
+func (o Options) bannerTimeout() time.Duration {
+	if o.BannerTimeout <= 0 {
+		return DefaultBannerTimeout
+	}
+	return o.BannerTimeout
+}
+
+// probeTimeout возвращает фактическое время ожидания ответа на пробу
+func (o Options) probeTimeout() time.Duration {
+	if o.ProbeTimeout <= 0 {
+		return DefaultProbeTimeout
+	}
+	return o.ProbeTimeout
+}
+
+// expiryWarning возвращает фактический срок предупреждения
+// об истечении сертификата
+func (o Options) expiryWarning() time.Duration {
+	if o.ExpiryWarning <= 0 {
+		return DefaultExpiryWarning
+	}
+	return o.ExpiryWarning
+}
+
+// maxHosts возвращает фактическое ограничение количества адресов
+func (o Options) maxHosts() int {
+	if o.MaxHosts <= 0 {
+		return DefaultMaxHosts
+	}
+	return o.MaxHosts
+}
+
+// retries возвращает фактическое количество повторных попыток
+func (o Options) retries() int {
+	if o.Retries < 0 {
+		return 0
+	}
+	return o.Retries
+}
+
+// Run сканирует порты ports на всех хостах из списка hl
+// с параметрами по умолчанию. Если список портов недопустим,
+// Run возвращает nil
 func Run(hl *HostsList, ports []int) []Results {
-	res := make([]Results, 0, len(hl.Hosts))
-	for _, h := range hl.Hosts {
-		r := Results{
-			Host: h,
-		}
-		if _, err := net.LookupHost(h); err != nil {
-			r.NotFound = true
-			res = append(res, r)
-			continue
+	res, _ := RunContext(context.Background(), hl, ports, Options{})
+	return res
+}
+
+// RunContext сканирует порты ports на всех хостах из списка hl.
+// Подсети и диапазоны из hl раскрываются в отдельные адреса,
+// а при opts.IPVersion каждый адрес хоста сканируется отдельно.
+// Проверки выполняются параллельно пулом из opts.Workers горутин,
+// при opts.Randomize - в случайном порядке, но порядок результатов
+// всегда совпадает с порядком хостов в hl, их адресов и портов в ports.
+//
+// При отмене ctx сканирование прекращается, а RunContext возвращает
+// уже собранные результаты вместе с ошибкой ctx.Err().
+// Недопустимые номера портов отклоняются до начала сканирования
+func RunContext(ctx context.Context, hl *HostsList, ports []int, opts Options) ([]Results, error) {
+	for _, p := range ports {
+		if err := ValidatePort(p); err != nil {
+			return nil, err
+		}
+	}
+
+	hosts, err := expandHosts(hl.Hosts, opts.maxHosts())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Results, len(hosts))
+	for i, h := range hosts {
+		res[i].Host = h
+	}
+
+	workers := opts.workers()
+
+	// Сначала проверяем, какие хосты существуют
+	resolved := make([]bool, len(res))
+	runPool(ctx, workers, len(res), func(i int) {
+		res[i].ScannedAt = time.Now()
+		addrs, err := lookupHost(ctx, opts.resolver(), res[i].Host, opts.IPVersion)
+		if ctx.Err() != nil {
+			return
 		}
+		resolved[i] = true
+		res[i].Addresses = addrs
+		if err != nil {
+			res[i].NotFound = true
+			return
+		}
+		if len(ports) > 0 {
+			res[i].PortStates = make([]PortState, len(ports))
+		}
+	})
+
+	// origin - индексы исходных хостов результатов. Ограничение
+	// HostConcurrency общее для всех адресов одного хоста
+	n := len(res)
+	origin := make([]int, n)
+	for i := range origin {
+		origin[i] = i
+	}
+	if opts.IPVersion != IPAny {
+		res, resolved, origin = splitAddresses(res, resolved)
+	}
 
-		for _, p := range ports {
-			r.PortStates = append(r.PortStates, scanPort(h, p))
+	// Затем сканируем порты найденных хостов. Каждая задача пишет
+	// в свою ячейку PortStates, поэтому синхронизация не нужна.
+	// При ограничении проверок одного хоста задачи чередуются
+	// по хостам, чтобы горутины не простаивали в ожидании
+	// одного хоста
+	jobs := make([]portJob, 0, len(res)*len(ports))
+	if opts.HostConcurrency > 0 {
+		for p := range ports {
+			for h := range res {
+				if resolved[h] && !res[h].NotFound {
+					jobs = append(jobs, portJob{h, p})
+				}
+			}
 		}
+	} else {
+		for h := range res {
+			if !resolved[h] || res[h].NotFound {
+				continue
+			}
+			for p := range ports {
+				jobs = append(jobs, portJob{h, p})
+			}
+		}
+	}
 
-		res = append(res, r)
+	if opts.Randomize {
+		seed := opts.Seed
+		if opts.RandomSeed {
+			seed = rand.Uint64()
+		}
+		rnd := rand.New(rand.NewPCG(seed, seed))
+		rnd.Shuffle(len(jobs), func(i, j int) {
+			jobs[i], jobs[j] = jobs[j], jobs[i]
+		})
 	}
-	return res
+
+	th := newThrottle(opts, n)
+	scanned := make([]bool, len(jobs))
+	runPool(ctx, workers, len(jobs), func(i int) {
+		j := jobs[i]
+		if err := th.acquireHost(ctx, origin[j.host]); err != nil {
+			return
+		}
+		defer th.releaseHost(origin[j.host])
+
+		ps, err := scanPort(ctx, res[j.host].Host, res[j.host].IP, ports[j.port], opts, th)
+		if err != nil {
+			return
+		}
+		res[j.host].PortStates[j.port] = ps
+		scanned[i] = true
+	})
+
+	if err := ctx.Err(); err != nil {
+		return partialResults(res, resolved, jobs, scanned), err
+	}
+	return res, nil
+}
+
+// lookupHost возвращает адреса хоста host семейства v, полученные
+// от r. При IPAny адреса нужны только для проверки существования хоста
+func lookupHost(ctx context.Context, r Resolver, host string, v IPVersion) ([]string, error) {
+	ips, err := r.LookupNetIP(ctx, v.network(), host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, ip.Unmap().String())
+	}
+	return addrs, nil
+}
+
+// portJob - задача проверки одного порта: индексы хоста
+// в результатах и порта в списке портов
+type portJob struct {
+	host, port int
+}
+
+// partialResults оставляет в res только хосты и порты,
+// проверка которых успела завершиться до отмены сканирования
+func partialResults(res []Results, resolved []bool, jobs []portJob, scanned []bool) []Results {
+	done := make([][]bool, len(res))
+	for i, j := range jobs {
+		if scanned[i] {
+			if done[j.host] == nil {
+				done[j.host] = make([]bool, len(res[j.host].PortStates))
+			}
+			done[j.host][j.port] = true
+		}
+	}
+
+	partial := make([]Results, 0, len(res))
+	for h, r := range res {
+		if !resolved[h] {
+			continue
+		}
+		states := r.PortStates
+		r.PortStates = nil
+		for p, ps := range states {
+			if done[h] != nil && done[h][p] {
+				r.PortStates = append(r.PortStates, ps)
+			}
+		}
+		partial = append(partial, r)
+	}
+	return partial
+}
+
+// runPool выполняет fn для каждого индекса от 0 до n-1
+// не более чем в workers горутинах. После отмены ctx новые
+// индексы в работу не передаются
+func runPool(ctx context.Context, workers, n int, fn func(i int)) {
+	if n == 0 {
+		return
+	}
+	if workers > n {
+		workers = n
+	}
+
+	idx := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				fn(i)
+			}
+		}()
+	}
+
+feed:
+	for i := range n {
+		select {
+		case idx <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(idx)
+	wg.Wait()
 }
@@ -1,9 +1,14 @@
 package scan_test
 
 import (
+	"context"
+	"errors"
 	"net"
+	"slices"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"vegorov.ru/go-cli/pScan/scan"
 )
@@ -21,6 +26,35 @@ func TestStateString(t *testing.T) {
 	}
 }
 
+// TestStateStringStatuses проверяет, что устаревший признак Open
+// выводит "closed" для любого не открытого порта, а различает
+// эти состояния только Status
+func TestStateStringStatuses(t *testing.T) {
+	testCases := []struct {
+		status    scan.Status
+		expStatus string
+		expOpen   string
+	}{
+		{scan.StatusOpen, "open", "open"},
+		{scan.StatusClosed, "closed", "closed"},
+		{scan.StatusFiltered, "filtered", "closed"},
+		{scan.StatusError, "error", "closed"},
+		{scan.StatusOpenFiltered, "open|filtered", "closed"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expStatus, func(t *testing.T) {
+			ps := scan.PortState{Open: tc.status == scan.StatusOpen, Status: tc.status}
+			if ps.Open.String() != tc.expOpen {
+				t.Errorf("Ожидали Open: %q, получили: %q\n", tc.expOpen, ps.Open.String())
+			}
+			if ps.Status.String() != tc.expStatus {
+				t.Errorf("Ожидали Status: %q, получили: %q\n", tc.expStatus, ps.Status.String())
+			}
+		})
+	}
+}
+
 func TestRunHostFound(t *testing.T) {
 	testCases := []struct {
 		name          string
@@ -86,6 +120,10 @@ func TestRunHostFound(t *testing.T) {
 		if res[0].PortStates[i].Open.String() != tc.expectedState {
 			t.Errorf("Ожидали порт %d в состоянии: %s\n", ports[i], tc.expectedState)
 		}
+
+		if res[0].PortStates[i].Status.String() != tc.expectedState {
+			t.Errorf("Ожидали порт %d в состоянии: %s, получили: %s\n", ports[i], tc.expectedState, res[0].PortStates[i].Status)
+		}
 	}
 }
 
@@ -112,3 +150,262 @@ func TestRunHostNotFound(t *testing.T) {
 		t.Fatalf("Ожидали 0 состояний портов, получили: %d\n", len(res[0].PortStates))
 	}
 }
+
+func TestRunContextOrder(t *testing.T) {
+	host := "localhost"
+	hl := &scan.HostsList{}
+	hl.Add(host)
+
+	ports := []int{}
+	expected := []string{}
+
+	// Открытые и закрытые порты вперемешку
+	for i := 0; i < 10; i++ {
+		ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+
+		_, portStr, err := net.SplitHostPort(ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ports = append(ports, port)
+
+		if i%2 == 1 {
+			ln.Close()
+			expected = append(expected, "closed")
+			continue
+		}
+		expected = append(expected, "open")
+	}
+
+	res, err := scan.RunContext(context.Background(), hl, ports, scan.Options{Workers: 4})
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+	}
+
+	if len(res) != 1 {
+		t.Fatalf("Ожидали 1 результат, а получили: %d\n", len(res))
+	}
+
+	if len(res[0].PortStates) != len(ports) {
+		t.Fatalf("Ожидали %d состояний портов, получили %d\n", len(ports), len(res[0].PortStates))
+	}
+
+	for i, ps := range res[0].PortStates {
+		if ps.Port != ports[i] {
+			t.Errorf("Ожидали порт: %d по индексу %d, получили: %d\n", ports[i], i, ps.Port)
+		}
+
+		if ps.Open.String() != expected[i] {
+			t.Errorf("Ожидали порт %d в состоянии: %s\n", ports[i], expected[i])
+		}
+	}
+}
+
+func TestRunContextCanceled(t *testing.T) {
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res, err := scan.RunContext(ctx, hl, []int{22, 80, 443}, scan.Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Ожидали ошибку %q, а получили: %v\n", context.Canceled, err)
+	}
+
+	// До отмены ничего не успели проверить, поэтому частичный
+	// результат не должен содержать ни одного порта
+	for _, r := range res {
+		if len(r.PortStates) != 0 {
+			t.Errorf("Ожидали 0 состояний портов, получили: %d\n", len(r.PortStates))
+		}
+	}
+}
+
+func TestRunContextRetries(t *testing.T) {
+	host := "localhost"
+	hl := &scan.HostsList{}
+	hl.Add(host)
+
+	// Занимаем порт, закрываем его и открываем снова через паузу,
+	// которая меньше суммарного времени повторных попыток
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	_, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lnCh := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			close(lnCh)
+			return
+		}
+		lnCh <- ln
+	}()
+
+	opts := scan.Options{
+		Timeout: 100 * time.Millisecond,
+		Retries: 5,
+		Backoff: 50 * time.Millisecond,
+	}
+
+	res, err := scan.RunContext(context.Background(), hl, []int{port}, opts)
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+	}
+
+	ln, ok := <-lnCh
+	if !ok {
+		t.Skip("Не удалось повторно занять порт")
+	}
+	defer ln.Close()
+
+	if res[0].PortStates[0].Open.String() != "open" {
+		t.Errorf("Ожидали порт %d в состоянии: open\n", port)
+	}
+}
+
+func TestStatusString(t *testing.T) {
+	testCases := []struct {
+		status   scan.Status
+		expected string
+	}{
+		{scan.StatusOpen, "open"},
+		{scan.StatusClosed, "closed"},
+		{scan.StatusFiltered, "filtered"},
+		{scan.StatusError, "error"},
+		{scan.StatusOpenFiltered, "open|filtered"},
+	}
+
+	for _, tc := range testCases {
+		if tc.status.String() != tc.expected {
+			t.Errorf("Ожидали: %q, получили: %q\n", tc.expected, tc.status.String())
+		}
+		if st, err := scan.ParseStatus(tc.expected); err != nil || st != tc.status {
+			t.Errorf("Ожидали разбор %q в %s, получили: %s, %v\n", tc.expected, tc.status, st, err)
+		}
+	}
+}
+
+// listenOrder запускает n серверов, записывающих в order порт
+// каждого принятого соединения до отправки приветствия.
+// Клиент ждёт приветствие, поэтому порядок записи совпадает
+// с порядком проверки портов при одной горутине
+func listenOrder(t *testing.T, n int, mu *sync.Mutex, order *[]int) []int {
+	t.Helper()
+
+	ports := make([]int, 0, n)
+	for range n {
+		ln, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ln.Close() })
+
+		port := ln.Addr().(*net.TCPAddr).Port
+		ports = append(ports, port)
+
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				*order = append(*order, port)
+				mu.Unlock()
+
+				conn.Write([]byte("hello\r\n"))
+				conn.Close()
+			}
+		}()
+	}
+	return ports
+}
+
+func TestRunContextRandomize(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []int
+	)
+	ports := listenOrder(t, 8, &mu, &order)
+
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+
+	// run сканирует порты с параметрами opts и возвращает порядок
+	// их проверки
+	run := func(opts scan.Options) []int {
+		mu.Lock()
+		order = nil
+		mu.Unlock()
+
+		opts.Workers, opts.Banner = 1, true
+		res, err := scan.RunContext(context.Background(), hl, ports, opts)
+		if err != nil {
+			t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+		}
+
+		// Результаты всегда в порядке портов
+		for i, ps := range res[0].PortStates {
+			if ps.Port != ports[i] || ps.Status != scan.StatusOpen {
+				t.Fatalf("Ожидали открытый порт %d на позиции %d, а получили %+v\n", ports[i], i, ps)
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(order)
+	}
+
+	if got := run(scan.Options{}); !slices.Equal(got, ports) {
+		t.Errorf("Без перемешивания ожидали порядок %v, а получили %v\n", ports, got)
+	}
+
+	first := run(scan.Options{Randomize: true, Seed: 42})
+	if slices.Equal(first, ports) {
+		t.Errorf("Ожидали случайный порядок, а получили исходный %v\n", first)
+	}
+	if !slices.Equal(slices.Sorted(slices.Values(first)), slices.Sorted(slices.Values(ports))) {
+		t.Errorf("Ожидали проверку каждого порта ровно один раз, а получили %v\n", first)
+	}
+
+	if again := run(scan.Options{Randomize: true, Seed: 42}); !slices.Equal(again, first) {
+		t.Errorf("С тем же seed ожидали порядок %v, а получили %v\n", first, again)
+	}
+	if other := run(scan.Options{Randomize: true, Seed: 7}); slices.Equal(other, first) {
+		t.Errorf("С другим seed ожидали другой порядок, а получили %v\n", other)
+	}
+
+	// С RandomSeed каждый запуск выбирает новый порядок, а Seed
+	// не используется. Три совпадения подряд практически невозможны
+	random := scan.Options{Randomize: true, Seed: 42, RandomSeed: true}
+	orders := [][]int{run(random), run(random), run(random)}
+	if slices.Equal(orders[0], orders[1]) && slices.Equal(orders[1], orders[2]) {
+		t.Errorf("С RandomSeed ожидали разный порядок запусков, а получили %v\n", orders[0])
+	}
+}
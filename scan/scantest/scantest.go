@@ -0,0 +1,277 @@
+// Package scantest предоставляет поддельные scan.Dialer и
+// scan.Resolver для детерминированных тестов сканирования без
+// настоящих сокетов и DNS. Исход каждого соединения задаётся
+// сценарием для адреса, а адреса имён - таблицей:
+//
+//	d := scantest.NewDialer()
+//	d.Script("tcp", "192.0.2.1:22", scantest.Open("SSH-2.0-OpenSSH_9.6\r\n"))
+//	d.Script("tcp", "192.0.2.1:80", scantest.Timeout(), scantest.Open(""))
+//	r := scantest.NewResolver()
+//	r.Add("host1", "192.0.2.1", "2001:db8::1")
+//	res, err := scan.RunContext(ctx, hl, ports, scan.Options{Dialer: d, Resolver: r})
+package scantest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrTimeout - ошибка соединения, не получившего ответа. Как и
+// ошибки net.Dialer, она сообщает Timeout() == true
+var ErrTimeout error = timeoutError{}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// Outcome - исход одного соединения
+type Outcome struct {
+	// Err - ошибка соединения. nil означает, что соединение
+	// устанавливается
+	Err error
+
+	// Delay - задержка перед исходом. Отмена ctx прерывает её
+	Delay time.Duration
+
+	// Greeting - данные, которые сервер отправляет сразу после
+	// соединения. Для UDP - ответ на первую датаграмму
+	Greeting []byte
+
+	// Serve - обработчик серверной стороны соединения. Если задан,
+	// Greeting не используется, а соединение закрывается после
+	// возврата из Serve
+	Serve func(conn net.Conn)
+}
+
+// Open возвращает исход открытого порта, сервис которого
+// отправляет greeting. Пустое приветствие - сервис молчит
+func Open(greeting string) Outcome {
+	return Outcome{Greeting: []byte(greeting)}
+}
+
+// Refused возвращает исход закрытого порта: для TCP - RST,
+// для UDP - ICMP port unreachable
+func Refused() Outcome {
+	return Fail(syscall.ECONNREFUSED)
+}
+
+// Timeout возвращает исход фильтруемого порта, соединение
+// с которым не получило ответа
+func Timeout() Outcome {
+	return Fail(ErrTimeout)
+}
+
+// Fail возвращает исход соединения, завершившегося ошибкой err
+func Fail(err error) Outcome {
+	return Outcome{Err: err}
+}
+
+// Dialer - поддельный scan.Dialer. Соединения с адресом получают
+// исходы из его сценария по очереди, последний исход повторяется.
+// Адреса без сценария получают исход Default
+type Dialer struct {
+	// Default - исход соединения с адресом без сценария.
+	// NewDialer устанавливает Refused
+	Default Outcome
+
+	mu      sync.Mutex
+	scripts map[string][]Outcome
+	dials   []string
+}
+
+// NewDialer возвращает поддельный Dialer, для которого
+// все порты без сценария закрыты
+func NewDialer() *Dialer {
+	return &Dialer{Default: Refused()}
+}
+
+// key возвращает ключ сценария для сети network и адреса address
+func key(network, address string) string {
+	return network + " " + address
+}
+
+// Script задаёт исходы последовательных соединений по сети
+// network ("tcp" или "udp") с адресом address вида "host:port"
+func (d *Dialer) Script(network, address string, outcomes ...Outcome) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.scripts == nil {
+		d.scripts = make(map[string][]Outcome)
+	}
+	d.scripts[key(network, address)] = outcomes
+}
+
+// Dials возвращает выполненные соединения в порядке их начала
+// в виде "сеть адрес", например "tcp 192.0.2.1:22"
+func (d *Dialer) Dials() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]string(nil), d.dials...)
+}
+
+// next записывает соединение и возвращает его исход
+func (d *Dialer) next(network, address string) Outcome {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	k := key(network, address)
+	d.dials = append(d.dials, k)
+
+	script := d.scripts[k]
+	switch len(script) {
+	case 0:
+		return d.Default
+	case 1:
+		return script[0]
+	}
+	d.scripts[k] = script[1:]
+	return script[0]
+}
+
+// DialContext возвращает соединение по сценарию адреса address.
+// Установленное соединение - один конец net.Pipe, на другом
+// конце работает поддельный сервер
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	o := d.next(network, address)
+
+	if o.Delay > 0 {
+		t := time.NewTimer(o.Delay)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return nil, dialError(network, address, ctx.Err())
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, dialError(network, address, err)
+	}
+
+	if o.Err != nil {
+		return nil, dialError(network, address, o.Err)
+	}
+
+	client, server := net.Pipe()
+	go serve(server, network, o)
+	return client, nil
+}
+
+// dialError оборачивает err так же, как это делает net.Dialer
+func dialError(network, address string, err error) error {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		err = os.NewSyscallError("connect", errno)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = ErrTimeout
+	}
+	return &net.OpError{Op: "dial", Net: network, Addr: fakeAddr(address), Err: err}
+}
+
+// serve обслуживает серверную сторону соединения по исходу o
+func serve(conn net.Conn, network string, o Outcome) {
+	defer conn.Close()
+
+	if o.Serve != nil {
+		o.Serve(conn)
+		return
+	}
+
+	// Датаграмма UDP получает ответ, а клиенту TCP приветствие
+	// отправляется сразу. Остальные данные клиента читаются,
+	// пока он не закроет соединение
+	if network == "udp" {
+		buf := make([]byte, 64*1024)
+		if _, err := conn.Read(buf); err != nil {
+			return
+		}
+		if len(o.Greeting) > 0 {
+			conn.Write(o.Greeting)
+		}
+	} else if len(o.Greeting) > 0 {
+		go conn.Write(o.Greeting)
+	}
+	io.Copy(io.Discard, conn)
+}
+
+// fakeAddr - адрес в ошибках соединения
+type fakeAddr string
+
+func (a fakeAddr) Network() string { return "fake" }
+func (a fakeAddr) String() string  { return string(a) }
+
+// Resolver - поддельный scan.Resolver. Имена разрешаются в адреса,
+// добавленные Add, IP адреса - сами в себя, а остальные имена
+// не существуют
+type Resolver struct {
+	mu      sync.Mutex
+	hosts   map[string][]netip.Addr
+	lookups []string
+}
+
+// NewResolver возвращает поддельный Resolver без известных имён
+func NewResolver() *Resolver {
+	return &Resolver{}
+}
+
+// Add добавляет адреса addrs имени host. Неверный адрес
+// вызывает панику
+func (r *Resolver) Add(host string, addrs ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.hosts == nil {
+		r.hosts = make(map[string][]netip.Addr)
+	}
+	for _, a := range addrs {
+		r.hosts[host] = append(r.hosts[host], netip.MustParseAddr(a))
+	}
+}
+
+// Lookups возвращает разрешённые имена в порядке запросов
+func (r *Resolver) Lookups() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.lookups...)
+}
+
+// LookupNetIP возвращает адреса имени host сети network: "ip",
+// "ip4" или "ip6". Если подходящих адресов нет, возвращается
+// такая же ошибка, как у net.Resolver для несуществующего имени
+func (r *Resolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: host}
+	}
+
+	r.mu.Lock()
+	r.lookups = append(r.lookups, host)
+	addrs := r.hosts[host]
+	r.mu.Unlock()
+
+	if ip, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{ip}
+	}
+
+	var result []netip.Addr
+	for _, ip := range addrs {
+		if network == "ip" || (network == "ip4") == ip.Unmap().Is4() {
+			result = append(result, ip)
+		}
+	}
+	if len(result) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return result, nil
+}
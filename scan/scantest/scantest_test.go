@@ -0,0 +1,171 @@
+package scantest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/scan"
+	"vegorov.ru/go-cli/pScan/scan/scantest"
+)
+
+func TestDialerScript(t *testing.T) {
+	d := scantest.NewDialer()
+	d.Script("tcp", "192.0.2.1:80", scantest.Refused(), scantest.Timeout(), scantest.Open("hello"))
+
+	// Исходы идут по очереди, последний повторяется
+	expected := []error{syscall.ECONNREFUSED, scantest.ErrTimeout, nil, nil}
+	for i, exp := range expected {
+		conn, err := d.DialContext(context.Background(), "tcp", "192.0.2.1:80")
+		if !errors.Is(err, exp) || (exp == nil) != (err == nil) {
+			t.Fatalf("Соединение %d: ожидали ошибку %v, а получили %v\n", i, exp, err)
+		}
+		if conn == nil {
+			continue
+		}
+
+		greeting := make([]byte, 5)
+		if _, err := io.ReadFull(conn, greeting); err != nil || string(greeting) != "hello" {
+			t.Errorf("Ожидали приветствие %q, а получили %q (%v)\n", "hello", greeting, err)
+		}
+		conn.Close()
+	}
+
+	var netErr net.Error
+	_, err := d.DialContext(context.Background(), "udp", "192.0.2.1:80")
+	if !errors.Is(err, syscall.ECONNREFUSED) || !errors.As(err, &netErr) {
+		t.Errorf("Ожидали отказ для адреса без сценария, а получили %v\n", err)
+	}
+
+	dials := d.Dials()
+	if len(dials) != 5 || dials[0] != "tcp 192.0.2.1:80" || dials[4] != "udp 192.0.2.1:80" {
+		t.Errorf("Ожидали запись всех соединений, а получили %v\n", dials)
+	}
+}
+
+func TestDialerDelay(t *testing.T) {
+	d := scantest.NewDialer()
+	d.Script("tcp", "192.0.2.1:22", scantest.Outcome{Delay: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Истечение ctx выглядит как таймаут соединения net.Dialer
+	_, err := d.DialContext(ctx, "tcp", "192.0.2.1:22")
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Ожидали таймаут соединения, а получили %v\n", err)
+	}
+}
+
+func TestDialerServe(t *testing.T) {
+	d := scantest.NewDialer()
+	d.Script("tcp", "192.0.2.1:6379", scantest.Outcome{Serve: func(conn net.Conn) {
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(conn, buf); err == nil && string(buf) == "PING" {
+			conn.Write([]byte("+PONG\r\n"))
+		}
+	}})
+
+	conn, err := d.DialContext(context.Background(), "tcp", "192.0.2.1:6379")
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("PING"))
+	reply, err := io.ReadAll(conn)
+	if err != nil || string(reply) != "+PONG\r\n" {
+		t.Errorf("Ожидали ответ %q, а получили %q (%v)\n", "+PONG\r\n", reply, err)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	r := scantest.NewResolver()
+	r.Add("host1", "192.0.2.1", "2001:db8::1")
+
+	testCases := []struct {
+		name     string
+		network  string
+		host     string
+		expected []string
+	}{
+		{"Any", "ip", "host1", []string{"192.0.2.1", "2001:db8::1"}},
+		{"V4", "ip4", "host1", []string{"192.0.2.1"}},
+		{"V6", "ip6", "host1", []string{"2001:db8::1"}},
+		{"Literal", "ip", "198.51.100.7", []string{"198.51.100.7"}},
+		{"LiteralOtherFamily", "ip6", "198.51.100.7", nil},
+		{"Unknown", "ip", "host2", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			addrs, err := r.LookupNetIP(context.Background(), tc.network, tc.host)
+			if tc.expected == nil {
+				var dnsErr *net.DNSError
+				if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+					t.Fatalf("Ожидали ошибку несуществующего имени, а получили %v\n", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+			}
+
+			var got []string
+			for _, a := range addrs {
+				got = append(got, a.String())
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Errorf("Ожидали адреса %v, а получили %v\n", tc.expected, got)
+			}
+		})
+	}
+
+	if n := len(r.Lookups()); n != len(testCases) {
+		t.Errorf("Ожидали %d запросов, а получили %d\n", len(testCases), n)
+	}
+}
+
+func TestResolverRunContext(t *testing.T) {
+	r := scantest.NewResolver()
+	r.Add("host1", "192.0.2.1", "2001:db8::1")
+
+	d := scantest.NewDialer()
+	d.Script("tcp", "[2001:db8::1]:22", scantest.Open(""))
+
+	hl := &scan.HostsList{}
+	hl.Add("host1")
+	hl.Add("host2")
+
+	opts := scan.Options{Dialer: d, Resolver: r, IPVersion: scan.IPBoth}
+	res, err := scan.RunContext(context.Background(), hl, []int{22}, opts)
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	expected := []struct {
+		target string
+		status scan.Status
+	}{
+		{"host1 (192.0.2.1)", scan.StatusClosed},
+		{"host1 (2001:db8::1)", scan.StatusOpen},
+	}
+	if len(res) != len(expected)+1 {
+		t.Fatalf("Ожидали %d результата, а получили %d\n", len(expected)+1, len(res))
+	}
+	for i, exp := range expected {
+		if res[i].Target() != exp.target || res[i].PortStates[0].Status != exp.status {
+			t.Errorf("Ожидали %s в состоянии %s, а получили %s в состоянии %s\n",
+				exp.target, exp.status, res[i].Target(), res[i].PortStates[0].Status)
+		}
+	}
+	if !res[2].NotFound {
+		t.Errorf("Ожидали, что host2 не найден, а получили %+v\n", res[2])
+	}
+}
@@ -0,0 +1,259 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultMaxHosts - ограничение на количество адресов, получаемых
+// при раскрытии подсетей и диапазонов, если в Options не задано иное.
+// Защищает от случайного сканирования подсети /8
+const DefaultMaxHosts = 65536
+
+var (
+	ErrInvalidHost  = errors.New("недопустимая запись хоста")
+	ErrTooManyHosts = errors.New("слишком много адресов для сканирования")
+)
+
+// hostEntry - разобранная запись списка хостов: имя хоста или адрес,
+// подсеть CIDR или диапазон адресов
+type hostEntry struct {
+	name     string
+	prefix   netip.Prefix
+	from, to netip.Addr
+}
+
+// parseHostEntry разбирает запись списка хостов. Поддерживаются
+// подсети ("10.0.0.0/24", "2001:db8::/120") и диапазоны адресов
+// ("10.0.0.10-50", "10.0.0.10-10.0.1.20", "2001:db8::1-2001:db8::ff").
+// Всё остальное считается именем хоста
+func parseHostEntry(s string) (hostEntry, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return hostEntry{}, fmt.Errorf("%w: %q: %s", ErrInvalidHost, s, err)
+		}
+		return hostEntry{prefix: p.Masked()}, nil
+	}
+
+	// Имя хоста тоже может содержать дефис, поэтому диапазоном
+	// считаем только запись, начинающуюся с IP адреса
+	fromStr, toStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return hostEntry{name: s}, nil
+	}
+	from, err := netip.ParseAddr(fromStr)
+	if err != nil {
+		return hostEntry{name: s}, nil
+	}
+
+	to, err := netip.ParseAddr(toStr)
+	if err != nil {
+		// Сокращённая запись: только последний октет IPv4 адреса
+		last, convErr := strconv.Atoi(toStr)
+		if !from.Is4() || convErr != nil || last < 0 || last > 255 {
+			return hostEntry{}, fmt.Errorf("%w: %q: неверный конец диапазона", ErrInvalidHost, s)
+		}
+		b := from.As4()
+		b[3] = byte(last)
+		to = netip.AddrFrom4(b)
+	}
+
+	if from.BitLen() != to.BitLen() {
+		return hostEntry{}, fmt.Errorf("%w: %q: адреса разных семейств", ErrInvalidHost, s)
+	}
+	if to.Less(from) {
+		return hostEntry{}, fmt.Errorf("%w: %q: конец диапазона меньше начала", ErrInvalidHost, s)
+	}
+
+	return hostEntry{from: from, to: to}, nil
+}
+
+// validateHostEntry проверяет, что запись s можно сохранить строкой
+// файла хостов: она не пустая и не содержит пробелов и управляющих
+// символов
+func validateHostEntry(s string) error {
+	if s == "" {
+		return fmt.Errorf("%w: пустое значение", ErrInvalidHost)
+	}
+	if strings.ContainsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}) {
+		return fmt.Errorf("%w: %q: пробелы и управляющие символы недопустимы", ErrInvalidHost, s)
+	}
+	return nil
+}
+
+// String возвращает компактную запись для хранения в файле хостов
+func (e hostEntry) String() string {
+	switch {
+	case e.prefix.IsValid():
+		return e.prefix.String()
+	case e.from.IsValid():
+		if e.from.Is4() {
+			f, t := e.from.As4(), e.to.As4()
+			if f[0] == t[0] && f[1] == t[1] && f[2] == t[2] {
+				return fmt.Sprintf("%s-%d", e.from, t[3])
+			}
+		}
+		return e.from.String() + "-" + e.to.String()
+	default:
+		return e.name
+	}
+}
+
+// expand раскрывает запись в список адресов, но не более limit штук.
+// Для подсетей IPv4 крупнее /31 адреса сети и широковещательный
+// адрес пропускаются
+func (e hostEntry) expand(limit int) ([]string, error) {
+	var first, last netip.Addr
+
+	switch {
+	case e.prefix.IsValid():
+		first = e.prefix.Addr()
+		if hostBits := first.BitLen() - e.prefix.Bits(); hostBits >= 31 {
+			return nil, fmt.Errorf("%w: %s (ограничение %d)", ErrTooManyHosts, e, limit)
+		}
+		if first.Is4() && e.prefix.Bits() < 31 {
+			first = first.Next()
+		}
+		last = lastAddr(e.prefix)
+		if last.Is4() && e.prefix.Bits() < 31 {
+			last = last.Prev()
+		}
+	case e.from.IsValid():
+		first, last = e.from, e.to
+	default:
+		if limit < 1 {
+			return nil, fmt.Errorf("%w: %s (ограничение исчерпано)", ErrTooManyHosts, e)
+		}
+		return []string{e.name}, nil
+	}
+
+	var hosts []string
+	for a := first; a.IsValid() && !last.Less(a); a = a.Next() {
+		if len(hosts) == limit {
+			return nil, fmt.Errorf("%w: %s (ограничение %d)", ErrTooManyHosts, e, limit)
+		}
+		hosts = append(hosts, a.String())
+	}
+	return hosts, nil
+}
+
+// lastAddr возвращает последний адрес подсети p
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
+
+// expandHosts раскрывает подсети и диапазоны из списка хостов.
+// Если общее количество адресов превышает limit, возвращается
+// ошибка ErrTooManyHosts
+func expandHosts(hosts []string, limit int) ([]string, error) {
+	targets := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		e, err := parseHostEntry(h)
+		if err != nil {
+			return nil, err
+		}
+
+		expanded, err := e.expand(limit - len(targets))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, expanded...)
+	}
+	return targets, nil
+}
+
+var ErrUnknownIPVersion = errors.New("неизвестная версия IP")
+
+// IPVersion - семейство адресов, на которых сканируются хосты
+type IPVersion int
+
+const (
+	// IPAny - соединение устанавливается по имени хоста,
+	// адрес выбирает резолвер
+	IPAny IPVersion = iota
+	// IPv4 - сканируется каждый адрес IPv4 хоста
+	IPv4
+	// IPv6 - сканируется каждый адрес IPv6 хоста
+	IPv6
+	// IPBoth - сканируется каждый адрес хоста обоих семейств
+	IPBoth
+)
+
+func (v IPVersion) String() string {
+	switch v {
+	case IPAny:
+		return ""
+	case IPv4:
+		return "4"
+	case IPv6:
+		return "6"
+	case IPBoth:
+		return "both"
+	default:
+		return fmt.Sprintf("IPVersion(%d)", int(v))
+	}
+}
+
+// ParseIPVersion возвращает семейство адресов по его имени:
+// "4", "6" или "both". Пустая строка означает IPAny
+func ParseIPVersion(s string) (IPVersion, error) {
+	for _, v := range []IPVersion{IPAny, IPv4, IPv6, IPBoth} {
+		if v.String() == s {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnknownIPVersion, s)
+}
+
+// network возвращает имя сети для net.Resolver.LookupNetIP
+func (v IPVersion) network() string {
+	switch v {
+	case IPv4:
+		return "ip4"
+	case IPv6:
+		return "ip6"
+	default:
+		return "ip"
+	}
+}
+
+// splitAddresses заменяет каждый найденный хост из res отдельными
+// результатами для каждого его адреса. Признаки resolved
+// переносятся на новые результаты, а origin содержит для каждого
+// нового результата индекс исходного хоста в res
+func splitAddresses(res []Results, resolved []bool) (split []Results, splitResolved []bool, origin []int) {
+	split = make([]Results, 0, len(res))
+	splitResolved = make([]bool, 0, len(res))
+	origin = make([]int, 0, len(res))
+	for i, r := range res {
+		if !resolved[i] || r.NotFound {
+			split = append(split, r)
+			splitResolved = append(splitResolved, resolved[i])
+			origin = append(origin, i)
+			continue
+		}
+		for _, addr := range r.Addresses {
+			a := r
+			a.IP = addr
+			if r.PortStates != nil {
+				a.PortStates = make([]PortState, len(r.PortStates))
+			}
+			split = append(split, a)
+			splitResolved = append(splitResolved, true)
+			origin = append(origin, i)
+		}
+	}
+	return split, splitResolved, origin
+}
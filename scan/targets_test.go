@@ -0,0 +1,215 @@
+package scan_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+func TestAddNetworks(t *testing.T) {
+	testCases := []struct {
+		name        string
+		host        string
+		expected    string
+		expectedErr error
+	}{
+		{"Host", "my-host", "my-host", nil},
+		{"CIDR", "10.0.0.0/24", "10.0.0.0/24", nil},
+		{"CIDRNotMasked", "10.0.0.7/24", "10.0.0.0/24", nil},
+		{"CIDRv6", "2001:db8::/120", "2001:db8::/120", nil},
+		{"ShortRange", "10.0.0.10-50", "10.0.0.10-50", nil},
+		{"FullRange", "10.0.0.10-10.0.0.50", "10.0.0.10-50", nil},
+		{"WideRange", "10.0.0.10-10.0.1.5", "10.0.0.10-10.0.1.5", nil},
+		{"RangeV6", "2001:db8::1-2001:db8::ff", "2001:db8::1-2001:db8::ff", nil},
+		{"BadCIDR", "10.0.0.0/33", "", scan.ErrInvalidHost},
+		{"ReversedRange", "10.0.0.50-10", "", scan.ErrInvalidHost},
+		{"BadRangeEnd", "10.0.0.10-300", "", scan.ErrInvalidHost},
+		{"MixedRange", "10.0.0.1-2001:db8::1", "", scan.ErrInvalidHost},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hl := &scan.HostsList{}
+			err := hl.Add(tc.host)
+
+			if tc.expectedErr != nil {
+				if !errors.Is(err, tc.expectedErr) {
+					t.Fatalf("Ожидали ошибку %q, а получили %v\n", tc.expectedErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+			}
+
+			if hl.Hosts[0] != tc.expected {
+				t.Errorf("Ожидали запись %q, а получили %q\n", tc.expected, hl.Hosts[0])
+			}
+		})
+	}
+}
+
+func TestRunExpandsNetworks(t *testing.T) {
+	testCases := []struct {
+		name     string
+		host     string
+		expected []string
+	}{
+		{"CIDR", "127.0.0.0/30", []string{"127.0.0.1", "127.0.0.2"}},
+		{"CIDR31", "127.0.0.0/31", []string{"127.0.0.0", "127.0.0.1"}},
+		{"Range", "127.0.0.5-7", []string{"127.0.0.5", "127.0.0.6", "127.0.0.7"}},
+		{"CIDRv6", "::1/128", []string{"::1"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hl := &scan.HostsList{}
+			if err := hl.Add(tc.host); err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := scan.RunContext(context.Background(), hl, nil, scan.Options{})
+			if err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+			}
+
+			if len(res) != len(tc.expected) {
+				t.Fatalf("Ожидали %d результатов, а получили %d\n", len(tc.expected), len(res))
+			}
+
+			for i, r := range res {
+				if r.Host != tc.expected[i] {
+					t.Errorf("Ожидали хост %q, а получили %q\n", tc.expected[i], r.Host)
+				}
+			}
+		})
+	}
+}
+
+func TestRunTooManyHosts(t *testing.T) {
+	hl := &scan.HostsList{}
+	for _, h := range []string{"10.0.0.0/8", "2001:db8::/64"} {
+		if err := hl.Add(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := scan.RunContext(context.Background(), hl, nil, scan.Options{})
+	if !errors.Is(err, scan.ErrTooManyHosts) {
+		t.Fatalf("Ожидали ошибку %q, а получили %v\n", scan.ErrTooManyHosts, err)
+	}
+
+	hl = &scan.HostsList{}
+	hl.Add("10.0.0.0/24")
+	_, err = scan.RunContext(context.Background(), hl, nil, scan.Options{MaxHosts: 100})
+	if !errors.Is(err, scan.ErrTooManyHosts) {
+		t.Fatalf("Ожидали ошибку %q, а получили %v\n", scan.ErrTooManyHosts, err)
+	}
+}
+
+func TestParseIPVersion(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected scan.IPVersion
+		err      error
+	}{
+		{"", scan.IPAny, nil},
+		{"4", scan.IPv4, nil},
+		{"6", scan.IPv6, nil},
+		{"both", scan.IPBoth, nil},
+		{"5", 0, scan.ErrUnknownIPVersion},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			v, err := scan.ParseIPVersion(tc.in)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Ожидали ошибку %v, а получили %v\n", tc.err, err)
+			}
+			if v != tc.expected {
+				t.Errorf("Ожидали %v, а получили %v\n", tc.expected, v)
+			}
+		})
+	}
+}
+
+func TestRunContextIPVersion(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	type entry struct {
+		host, ip string
+		notFound bool
+		open     bool
+	}
+
+	testCases := []struct {
+		name     string
+		hosts    []string
+		version  scan.IPVersion
+		expected []entry
+	}{
+		{"Any", []string{"127.0.0.1"}, scan.IPAny,
+			[]entry{{"127.0.0.1", "", false, true}}},
+		{"V4", []string{"127.0.0.1", "::1"}, scan.IPv4,
+			[]entry{{"127.0.0.1", "127.0.0.1", false, true}, {"::1", "", true, false}}},
+		{"V6", []string{"127.0.0.1", "::1"}, scan.IPv6,
+			[]entry{{"127.0.0.1", "", true, false}, {"::1", "::1", false, false}}},
+		{"Both", []string{"127.0.0.1", "::1"}, scan.IPBoth,
+			[]entry{{"127.0.0.1", "127.0.0.1", false, true}, {"::1", "::1", false, false}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hl := &scan.HostsList{}
+			for _, h := range tc.hosts {
+				hl.Add(h)
+			}
+
+			res, err := scan.RunContext(context.Background(), hl, []int{port}, scan.Options{IPVersion: tc.version})
+			if err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+			}
+			if len(res) != len(tc.expected) {
+				t.Fatalf("Ожидали %d результатов, а получили %d\n", len(tc.expected), len(res))
+			}
+
+			for i, exp := range tc.expected {
+				r := res[i]
+				if r.Host != exp.host || r.IP != exp.ip || r.NotFound != exp.notFound {
+					t.Errorf("Ожидали %s (%q, не найден: %t), а получили %s (%q, не найден: %t)\n",
+						exp.host, exp.ip, exp.notFound, r.Host, r.IP, r.NotFound)
+					continue
+				}
+				if !exp.notFound && (r.PortStates[0].Status == scan.StatusOpen) != exp.open {
+					t.Errorf("Ожидали открытый порт: %t на %s, а получили %s\n", exp.open, r.Target(), r.PortStates[0].Status)
+				}
+			}
+		})
+	}
+
+	// Имя хоста сканируется на каждом своём адресе: сервер слушает
+	// только 127.0.0.1, поэтому на остальных адресах порт не открыт
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+	res, err := scan.RunContext(context.Background(), hl, []int{port}, scan.Options{IPVersion: scan.IPBoth})
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+	for _, r := range res {
+		if r.Host != "localhost" || r.IP == "" {
+			t.Fatalf("Ожидали адрес сканирования хоста localhost, а получили %+v\n", r)
+		}
+		if open := r.PortStates[0].Status == scan.StatusOpen; open != (r.IP == "127.0.0.1") {
+			t.Errorf("Ожидали открытый порт: %t на %s, а получили %s\n", !open, r.Target(), r.PortStates[0].Status)
+		}
+	}
+}
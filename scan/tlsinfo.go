@@ -0,0 +1,139 @@
+package scan
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"time"
+)
+
+// DefaultExpiryWarning - за сколько до истечения срока действия
+// сертификат считается истекающим
+const DefaultExpiryWarning = 30 * 24 * time.Hour
+
+// TLSInfo - параметры TLS соединения и сертификат сервера
+type TLSInfo struct {
+	// Version и CipherSuite - согласованные версия протокола
+	// и набор шифров, например "TLS 1.3" и "TLS_AES_128_GCM_SHA256"
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipher_suite"`
+
+	// Subject, SANs, Issuer, NotBefore и NotAfter - данные
+	// сертификата сервера
+	Subject   string    `json:"subject"`
+	SANs      []string  `json:"sans"`
+	Issuer    string    `json:"issuer"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+
+	// Expired - срок действия сертификата истёк
+	Expired bool `json:"expired"`
+	// ExpiresSoon - срок действия истекает в пределах
+	// Options.ExpiryWarning
+	ExpiresSoon bool `json:"expires_soon"`
+	// SelfSigned - цепочка сертификатов заканчивается
+	// самоподписанным сертификатом, которому нет доверия
+	SelfSigned bool `json:"self_signed"`
+}
+
+// Flags возвращает признаки проблем с сертификатом:
+// expired, expires-soon и self-signed
+func (t *TLSInfo) Flags() []string {
+	flags := []string{}
+	if t.Expired {
+		flags = append(flags, "expired")
+	}
+	if t.ExpiresSoon {
+		flags = append(flags, "expires-soon")
+	}
+	if t.SelfSigned {
+		flags = append(flags, "self-signed")
+	}
+	return flags
+}
+
+// inspectTLS устанавливает TLS соединение и возвращает его параметры
+// и сертификат сервера. Сертификат не проверяется, чтобы можно было
+// исследовать в том числе недоверенные. serverName передаётся в SNI.
+// Если порт не поддерживает TLS, возвращается nil
+func inspectTLS(ctx context.Context, dial dialFunc, serverName string, timeout, warn time.Duration) *TLSInfo {
+	conn, err := dial(ctx)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	cfg := &tls.Config{InsecureSkipVerify: true}
+	if net.ParseIP(serverName) == nil {
+		cfg.ServerName = serverName
+	}
+	tc := tls.Client(conn, cfg)
+
+	hctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := tc.HandshakeContext(hctx); err != nil {
+		return nil
+	}
+
+	state := tc.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	chain := state.PeerCertificates
+	cert := chain[0]
+
+	now := time.Now()
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		Subject:     cert.Subject.String(),
+		SANs:        certSANs(cert),
+		Issuer:      cert.Issuer.String(),
+		NotBefore:   cert.NotBefore,
+		NotAfter:    cert.NotAfter,
+		Expired:     now.After(cert.NotAfter),
+	}
+	info.ExpiresSoon = !info.Expired && now.Add(warn).After(cert.NotAfter)
+	info.SelfSigned = isSelfSigned(chain[len(chain)-1]) && !verifyChain(chain)
+
+	return info
+}
+
+// certSANs возвращает альтернативные имена сертификата:
+// DNS имена, адреса, адреса почты и URI
+func certSANs(cert *x509.Certificate) []string {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	return sans
+}
+
+// isSelfSigned сообщает, подписан ли сертификат собственным ключом.
+// Подпись проверяется напрямую, а не CheckSignatureFrom, так как
+// самоподписанный сертификат сервера обычно не помечен как CA
+func isSelfSigned(cert *x509.Certificate) bool {
+	return cert.Issuer.String() == cert.Subject.String() &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// verifyChain сообщает, доверяет ли система цепочке сертификатов.
+// Имя сервера и срок действия не проверяются: срок учитывается
+// отдельно, в признаках Expired и ExpiresSoon
+func verifyChain(chain []*x509.Certificate) bool {
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Intermediates: intermediates,
+		CurrentTime:   chain[0].NotBefore.Add(time.Second),
+	})
+	return err == nil
+}
@@ -0,0 +1,202 @@
+package scan_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+// listenTLS запускает TLS сервер с самоподписанным сертификатом,
+// действующим до notAfter
+func listenTLS(t *testing.T, notAfter time.Time) int {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "old.example.com"},
+		DNSNames:     []string{"old.example.com"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := tls.Listen("tcp", "localhost:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestRunContextTLS(t *testing.T) {
+	// newServer запускает сервер так же, как httptest.NewTLSServer
+	ts := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), true)
+
+	testCases := []struct {
+		name           string
+		listen         func(t *testing.T) int
+		warning        time.Duration
+		expTLS         bool
+		expSubject     string
+		expSAN         string
+		expFlags       []string
+		expVersionPref string
+	}{
+		{"HTTPS", func(t *testing.T) int { return serverPort(t, ts) },
+			0, true, "O=Acme Co", "127.0.0.1", []string{"self-signed"}, "TLS 1."},
+		{"NearExpiry", func(t *testing.T) int { return serverPort(t, ts) },
+			100 * 365 * 24 * time.Hour, true, "O=Acme Co", "example.com", []string{"expires-soon", "self-signed"}, "TLS 1."},
+		{"Expired", func(t *testing.T) int { return listenTLS(t, time.Now().Add(-time.Hour)) },
+			0, true, "CN=old.example.com", "old.example.com", []string{"expired", "self-signed"}, "TLS 1."},
+		{"PlainText", func(t *testing.T) int { return listenGreeting(t, "SSH-2.0-OpenSSH_9.6\r\n") },
+			0, false, "", "", nil, ""},
+	}
+
+	hl := &scan.HostsList{}
+	hl.Add("localhost")
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			port := tc.listen(t)
+
+			opts := scan.Options{TLSInspect: true, ExpiryWarning: tc.warning, ProbeTimeout: time.Second}
+			res, err := scan.RunContext(context.Background(), hl, []int{port}, opts)
+			if err != nil {
+				t.Fatalf("Не ожидали ошибку, а получили: %q\n", err)
+			}
+
+			info := res[0].PortStates[0].TLS
+			if !tc.expTLS {
+				if info != nil {
+					t.Errorf("Не ожидали данных TLS, а получили %+v\n", info)
+				}
+				return
+			}
+			if info == nil {
+				t.Fatal("Ожидали данные TLS, а получили nil")
+			}
+
+			if !strings.HasPrefix(info.Version, tc.expVersionPref) || info.CipherSuite == "" {
+				t.Errorf("Неверные параметры TLS: %q %q\n", info.Version, info.CipherSuite)
+			}
+			if info.Subject != tc.expSubject || info.Issuer != tc.expSubject {
+				t.Errorf("Ожидали субъект и издателя %q, а получили %q и %q\n", tc.expSubject, info.Subject, info.Issuer)
+			}
+			if !slices.Contains(info.SANs, tc.expSAN) {
+				t.Errorf("Ожидали %q среди альтернативных имён %q\n", tc.expSAN, info.SANs)
+			}
+			if !reflect.DeepEqual(info.Flags(), tc.expFlags) {
+				t.Errorf("Ожидали признаки %q, а получили %q\n", tc.expFlags, info.Flags())
+			}
+		})
+	}
+}
+
+func TestTLSOutput(t *testing.T) {
+	notAfter := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	results := []scan.Results{{
+		Host:      "localhost",
+		Addresses: []string{"127.0.0.1"},
+		PortStates: []scan.PortState{{
+			Port:   443,
+			Open:   true,
+			Status: scan.StatusOpen,
+			TLS: &scan.TLSInfo{
+				Version:     "TLS 1.3",
+				CipherSuite: "TLS_AES_128_GCM_SHA256",
+				Subject:     "CN=example.com",
+				SANs:        []string{"example.com", "www.example.com"},
+				Issuer:      "CN=example.com",
+				NotBefore:   notAfter.AddDate(-1, 0, 0),
+				NotAfter:    notAfter,
+				SelfSigned:  true,
+				ExpiresSoon: true,
+			},
+		}},
+	}}
+
+	t.Run("JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := scan.WriteJSON(&out, results); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), `"cipher_suite": "TLS_AES_128_GCM_SHA256"`) {
+			t.Errorf("Ожидали данные TLS в выводе:\n%s\n", out.String())
+		}
+
+		res, err := scan.ReadJSON(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(res[0].PortStates[0].TLS, results[0].PortStates[0].TLS) {
+			t.Errorf("Ожидали %+v, а получили %+v\n", results[0].PortStates[0].TLS, res[0].PortStates[0].TLS)
+		}
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		var out bytes.Buffer
+		columns := []string{"port", "tls_version", "cert_sans", "cert_not_after", "cert_flags"}
+		if err := scan.WriteCSV(&out, results, columns, ','); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "port,tls_version,cert_sans,cert_not_after,cert_flags\n" +
+			"443,TLS 1.3,example.com www.example.com,2026-01-02T03:04:05Z,expires-soon self-signed\n"
+		if out.String() != expected {
+			t.Errorf("Ожидали вывод %q, а получили %q\n", expected, out.String())
+		}
+	})
+
+	t.Run("NmapXML", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := scan.WriteNmapXML(&out, results, "pScan scan"); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `<script id="ssl-cert" output="Subject: CN=example.com&#xA;` +
+			`Subject Alternative Name: example.com, www.example.com&#xA;Issuer: CN=example.com&#xA;` +
+			`Not valid before: 2025-01-02T03:04:05&#xA;Not valid after:  2026-01-02T03:04:05"></script>`
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Ожидали %s в выводе:\n%s\n", expected, out.String())
+		}
+	})
+}
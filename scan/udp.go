@@ -0,0 +1,155 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// MaxUDPResponse - наибольший размер ответа на UDP датаграмму
+const MaxUDPResponse = 1500
+
+// udpPayload - датаграмма, на которую сервис известного порта
+// отвечает, и имя этого сервиса
+type udpPayload struct {
+	service string
+	data    []byte
+}
+
+// dnsQuery - запрос NS записей корневой зоны
+var dnsQuery = []byte("\x12\x34\x01\x00\x00\x01\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x02\x00\x01")
+
+// udpPayloads - датаграммы для известных UDP портов. Сервисы
+// без ответа на пустую датаграмму получают запрос своего протокола
+var udpPayloads = map[int]udpPayload{
+	53: {"domain", dnsQuery},
+	// NTP версии 4, режим клиента
+	123: {"ntp", append([]byte{0xe3}, make([]byte, 47)...)},
+	// Запрос состояния узла NetBIOS (NBSTAT) для имени "*"
+	137: {"netbios-ns", []byte("\x80\xf0\x00\x10\x00\x01\x00\x00\x00\x00\x00\x00" +
+		"\x20CKAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\x00\x00\x21\x00\x01")},
+	// SNMPv1 GetRequest sysDescr.0 с сообществом public
+	161: {"snmp", []byte("\x30\x29\x02\x01\x00\x04\x06public\xa0\x1c\x02\x04\x70\x53\x63\x6e" +
+		"\x02\x01\x00\x02\x01\x00\x30\x0e\x30\x0c\x06\x08\x2b\x06\x01\x02\x01\x01\x01\x00\x05\x00")},
+	// syslog не отвечает, поэтому порт будет открыт или фильтруется,
+	// но сообщение хотя бы объясняет администратору источник
+	514: {"syslog", []byte("<14>pScan: UDP probe\n")},
+	1900: {"ssdp", []byte("M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\nMX: 1\r\nST: ssdp:all\r\n\r\n")},
+	5353: {"mdns", dnsQuery},
+}
+
+// udpServiceName возвращает имя сервиса на UDP порту port или
+// пустую строку. Таблица сервисов содержит только TCP порты,
+// поэтому известны лишь сервисы портов с датаграммами udpPayloads
+func udpServiceName(port int) string {
+	return udpPayloads[port].service
+}
+
+// classifyUDP определяет состояние UDP порта по ошибке обмена.
+// Ошибки ICMP unreachable сокет возвращает при следующей операции:
+// port unreachable означает закрытый порт, остальные - фильтрацию
+func classifyUDP(err error) Status {
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return StatusClosed
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH),
+		errors.Is(err, syscall.EACCES):
+		return StatusFiltered
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return StatusOpenFiltered
+	}
+	return StatusError
+}
+
+// scanUDPPort проверяет UDP порт port по адресу addr: отправляет
+// датаграмму протокола порта или пустую и ждёт ответа opts.Timeout.
+// Без ответа датаграмма повторяется opts.Retries раз, после чего
+// порт считается открытым или фильтруемым.
+// Ошибка возвращается только в случае отмены ctx
+func scanUDPPort(ctx context.Context, addr string, port int, opts Options, th *throttle) (PortState, error) {
+	p := PortState{
+		Port:     port,
+		Protocol: "udp",
+	}
+	address := net.JoinHostPort(addr, fmt.Sprintf("%d", port))
+	payload := udpPayloads[port].data
+
+	for attempt := 0; attempt <= opts.retries(); attempt++ {
+		if attempt > 0 && opts.Backoff > 0 {
+			if err := sleep(ctx, opts.Backoff); err != nil {
+				return p, err
+			}
+		}
+
+		if err := th.beforeDial(ctx); err != nil {
+			return p, err
+		}
+
+		start := time.Now()
+		reply, err := exchangeUDP(ctx, opts.dialer(), address, payload, opts.timeout())
+		p.Latency = time.Since(start)
+		if ctx.Err() != nil {
+			return p, ctx.Err()
+		}
+		if err != nil {
+			p.Status = classifyUDP(err)
+			p.Reason = err.Error()
+			if p.Status == StatusOpenFiltered {
+				continue
+			}
+			return p, nil
+		}
+
+		p.Open = true
+		p.Status = StatusOpen
+		p.Reason = ""
+		p.Service = udpServiceName(port)
+		if opts.Banner {
+			p.Banner = sanitizeBanner(reply[:min(len(reply), MaxBannerSize)])
+		}
+		return p, nil
+	}
+
+	return p, nil
+}
+
+// exchangeUDP отправляет датаграмму payload на address через d и возвращает
+// первый ответ, полученный за timeout
+func exchangeUDP(ctx context.Context, d Dialer, address string, payload []byte, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := d.DialContext(ctx, "udp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Отмена ctx прерывает ожидание ответа
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(payload); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, MaxUDPResponse)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
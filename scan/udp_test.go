@@ -0,0 +1,124 @@
+package scan_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+// listenUDP запускает UDP сервер, отвечающий reply на каждую
+// датаграмму. Пустой ответ - сервер молчит
+func listenUDP(t *testing.T, reply string) int {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if reply != "" {
+				conn.WriteTo([]byte(reply), addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// closedUDPPort возвращает номер свободного UDP порта
+func closedUDPPort(t *testing.T) int {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+	return port
+}
+
+func TestRunContextUDP(t *testing.T) {
+	ports := []int{
+		listenUDP(t, "pong\r\n"),
+		listenUDP(t, ""),
+		closedUDPPort(t),
+	}
+
+	hl := &scan.HostsList{}
+	hl.Add("127.0.0.1")
+
+	opts := scan.Options{UDP: true, Banner: true, Timeout: 200 * time.Millisecond, Retries: 1}
+	res, err := scan.RunContext(context.Background(), hl, ports, opts)
+	if err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	expected := []struct {
+		status scan.Status
+		banner string
+	}{
+		{scan.StatusOpen, "pong"},
+		{scan.StatusOpenFiltered, ""},
+		{scan.StatusClosed, ""},
+	}
+
+	for i, exp := range expected {
+		ps := res[0].PortStates[i]
+		if ps.Status != exp.status {
+			t.Errorf("Ожидали порт %d в состоянии %s, а получили %s (%s)\n", ps.Port, exp.status, ps.Status, ps.Reason)
+		}
+		if ps.Protocol != "udp" {
+			t.Errorf("Ожидали протокол udp, а получили %q\n", ps.Protocol)
+		}
+		if ps.Banner != exp.banner {
+			t.Errorf("Ожидали ответ %q, а получили %q\n", exp.banner, ps.Banner)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := scan.WriteNmapGrepable(&out, res, "pScan scan --udp"); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"/open/udp/", "/open|filtered/udp/", "/closed/udp/"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("Ожидали %q в выводе, а получили:\n%s\n", s, out.String())
+		}
+	}
+}
+
+func TestUDPServiceNames(t *testing.T) {
+	res := []scan.Results{{
+		Host: "host1",
+		PortStates: []scan.PortState{
+			{Port: 53, Protocol: "udp", Status: scan.StatusOpenFiltered},
+			// Порт 80 известен только как TCP сервис http
+			{Port: 80, Protocol: "udp", Status: scan.StatusOpenFiltered},
+			{Port: 80, Status: scan.StatusClosed},
+		},
+	}}
+
+	var out bytes.Buffer
+	if err := scan.WriteNmapGrepable(&out, res, "pScan scan --udp"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"53/open|filtered/udp//domain//", "80/open|filtered/udp/////", "80/closed/tcp//http//"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("Ожидали %q в выводе, а получили:\n%s\n", s, out.String())
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package schedule периодически выполняет задания сканирования
+// и сохраняет их результаты в историю запусков
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/history"
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+var (
+	ErrJobRunning = errors.New("задание уже выполняется")
+	ErrInterval   = errors.New("недопустимый интервал задания")
+	ErrJobName    = errors.New("недопустимое имя задания")
+)
+
+// Job - задание сканирования: хосты из файла HostsFile сканируются
+// на портах Ports каждые Interval
+type Job struct {
+	Name      string
+	HostsFile string
+	Ports     []int
+	Interval  time.Duration
+	Options   scan.Options
+}
+
+// Scheduler выполняет задания по расписанию. Если предыдущий
+// запуск задания ещё не завершён, очередной запуск пропускается
+type Scheduler struct {
+	jobs    []Job
+	store   *history.Store
+	running map[string]*atomic.Bool
+
+	// OnRun вызывается после каждого успешного запуска задания
+	OnRun func(job Job, run history.Run)
+
+	logger *log.Logger
+	// scanFunc выполняет сканирование, в тестах подменяется
+	scanFunc func(context.Context, *scan.HostsList, []int, scan.Options) ([]scan.Results, error)
+}
+
+// New возвращает планировщик заданий jobs, сохраняющий результаты
+// в store. Сообщения о запусках пишутся в logOut
+func New(jobs []Job, store *history.Store, logOut io.Writer) (*Scheduler, error) {
+	s := &Scheduler{
+		jobs:     jobs,
+		store:    store,
+		running:  make(map[string]*atomic.Bool, len(jobs)),
+		logger:   log.New(logOut, "", log.LstdFlags),
+		scanFunc: scan.RunContext,
+	}
+
+	for _, j := range jobs {
+		if j.Name == "" {
+			return nil, fmt.Errorf("%w: пустое имя", ErrJobName)
+		}
+		if _, ok := s.running[j.Name]; ok {
+			return nil, fmt.Errorf("%w: %s повторяется", ErrJobName, j.Name)
+		}
+		if j.Interval <= 0 {
+			return nil, fmt.Errorf("%w: %s: %s", ErrInterval, j.Name, j.Interval)
+		}
+		s.running[j.Name] = &atomic.Bool{}
+	}
+
+	return s, nil
+}
+
+// Jobs возвращает задания планировщика
+func (s *Scheduler) Jobs() []Job {
+	return s.jobs
+}
+
+// Run запускает все задания сразу и затем каждые Interval, пока
+// не будет отменён ctx. После отмены дожидается завершения
+// выполняющихся запусков
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	start := func(j Job) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.RunJob(ctx, j)
+			switch {
+			case errors.Is(err, ErrJobRunning):
+				s.logger.Printf("задание %s: запуск пропущен, предыдущий ещё не завершён", j.Name)
+			case err != nil:
+				s.logger.Printf("задание %s: %s", j.Name, err)
+			}
+		}()
+	}
+
+	for _, j := range s.jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ticker := time.NewTicker(j.Interval)
+			defer ticker.Stop()
+
+			start(j)
+			for {
+				select {
+				case <-ticker.C:
+					start(j)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	wg.Wait()
+}
+
+// RunJob однократно выполняет задание job и сохраняет результат
+// в историю. Если задание уже выполняется, возвращает ErrJobRunning
+func (s *Scheduler) RunJob(ctx context.Context, job Job) (history.Run, error) {
+	running, ok := s.running[job.Name]
+	if !ok {
+		return history.Run{}, fmt.Errorf("%w: %s не зарегистрировано", ErrJobName, job.Name)
+	}
+	if !running.CompareAndSwap(false, true) {
+		return history.Run{}, ErrJobRunning
+	}
+	defer running.Store(false)
+
+	hl := &scan.HostsList{}
+	if err := hl.Load(job.HostsFile); err != nil {
+		return history.Run{}, err
+	}
+
+	start := time.Now()
+	results, err := s.scanFunc(ctx, hl, job.Ports, job.Options)
+	if err != nil {
+		return history.Run{}, err
+	}
+
+	run := history.Run{
+		Time:      start,
+		Job:       job.Name,
+		HostsFile: job.HostsFile,
+		Ports:     job.Ports,
+		Results:   results,
+	}
+	if s.store != nil {
+		if run, err = s.store.Add(run); err != nil {
+			return history.Run{}, err
+		}
+	}
+
+	s.logger.Printf("задание %s: просканировано хостов: %d за %s",
+		job.Name, len(results), time.Since(start).Round(time.Millisecond))
+
+	if s.OnRun != nil {
+		s.OnRun(job, run)
+	}
+	return run, nil
+}
@@ -0,0 +1,129 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vegorov.ru/go-cli/pScan/history"
+	"vegorov.ru/go-cli/pScan/scan"
+)
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name        string
+		jobs        []Job
+		expectedErr error
+	}{
+		{"Valid", []Job{{Name: "a", Interval: time.Minute}, {Name: "b", Interval: time.Hour}}, nil},
+		{"NoName", []Job{{Interval: time.Minute}}, ErrJobName},
+		{"Duplicate", []Job{{Name: "a", Interval: time.Minute}, {Name: "a", Interval: time.Hour}}, ErrJobName},
+		{"NoInterval", []Job{{Name: "a"}}, ErrInterval},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(tc.jobs, nil, io.Discard)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Ожидали ошибку %v, а получили %v\n", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestRunJobSkipsOverlap(t *testing.T) {
+	store := history.NewStore(t.TempDir())
+	job := Job{Name: "test", HostsFile: "no-such-file", Ports: []int{22}, Interval: time.Minute}
+
+	s, err := New([]Job{job}, store, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s.scanFunc = func(ctx context.Context, hl *scan.HostsList, ports []int, opts scan.Options) ([]scan.Results, error) {
+		close(started)
+		<-release
+		return []scan.Results{{Host: "localhost"}}, nil
+	}
+
+	var onRun atomic.Int32
+	s.OnRun = func(j Job, run history.Run) {
+		onRun.Add(1)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := s.RunJob(context.Background(), job)
+		done <- err
+	}()
+
+	<-started
+	if _, err := s.RunJob(context.Background(), job); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Ожидали ошибку %q, а получили %v\n", ErrJobRunning, err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Не ожидали ошибку, а получили %q\n", err)
+	}
+
+	runs, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Job != "test" || runs[0].Results[0].Host != "localhost" {
+		t.Errorf("Ожидали 1 запуск задания test в истории, а получили %+v\n", runs)
+	}
+
+	if onRun.Load() != 1 {
+		t.Errorf("Ожидали 1 вызов OnRun, а получили %d\n", onRun.Load())
+	}
+}
+
+func TestRun(t *testing.T) {
+	store := history.NewStore(t.TempDir())
+	job := Job{Name: "test", HostsFile: "no-such-file", Interval: 10 * time.Millisecond}
+
+	s, err := New([]Job{job}, store, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Сканирование дольше интервала: запуски не должны накладываться
+	var active, maxActive, calls atomic.Int32
+	s.scanFunc = func(ctx context.Context, hl *scan.HostsList, ports []int, opts scan.Options) ([]scan.Results, error) {
+		calls.Add(1)
+		n := active.Add(1)
+		defer active.Add(-1)
+		if n > maxActive.Load() {
+			maxActive.Store(n)
+		}
+		time.Sleep(35 * time.Millisecond)
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	if maxActive.Load() != 1 {
+		t.Errorf("Ожидали не более 1 одновременного запуска, а получили %d\n", maxActive.Load())
+	}
+
+	if calls.Load() < 2 || calls.Load() > 5 {
+		t.Errorf("Ожидали от 2 до 5 запусков, а получили %d\n", calls.Load())
+	}
+
+	runs, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != int(calls.Load()) {
+		t.Errorf("Ожидали %d запусков в истории, а получили %d\n", calls.Load(), len(runs))
+	}
+}